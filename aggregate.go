@@ -0,0 +1,171 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	AggregateSum   = "sum"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+	AggregateCount = "count"
+)
+
+var aggregateFunctions = map[string]bool{AggregateSum: true, AggregateAvg: true, AggregateMin: true, AggregateMax: true, AggregateCount: true}
+
+func (b *SearchBuilder) Aggregate(ctx context.Context, m interface{}) (map[string]interface{}, error) {
+	var aggregates []string
+	sm := GetSearchModel(m)
+	if sm != nil {
+		aggregates = sm.Aggregates
+	}
+	aggregates = MergeAggregates(GetAggregatesFromTag(b.ModelType), aggregates)
+	if hidden := GetHiddenFields(ctx); len(hidden) > 0 {
+		aggregates = excludeHidden(aggregates, hidden)
+	}
+	if len(aggregates) == 0 {
+		return nil, nil
+	}
+	query, params, err := b.Build(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return BuildAggregates(ctx, b.db(), query, params, aggregates, b.ModelType)
+}
+
+// GetAggregatesFromTag reads the aggregate tag of the model type, such as `aggregate:"sum,avg"`
+func GetAggregatesFromTag(modelType reflect.Type) []string {
+	aggregates := make([]string, 0)
+	if modelType.Kind() != reflect.Struct {
+		return aggregates
+	}
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		tag, ok := field.Tag.Lookup("aggregate")
+		if !ok || len(tag) == 0 {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(name) == 0 || name == "-" {
+			name = field.Name
+		}
+		for _, f := range strings.Split(tag, ",") {
+			f = strings.TrimSpace(f)
+			if len(f) > 0 {
+				aggregates = append(aggregates, f+"("+name+")")
+			}
+		}
+	}
+	return aggregates
+}
+func excludeHidden(aggregates []string, hidden map[string]bool) []string {
+	r := make([]string, 0)
+	for _, a := range aggregates {
+		if _, name, err := ParseAggregate(a); err == nil && hidden[name] {
+			continue
+		}
+		r = append(r, a)
+	}
+	return r
+}
+func MergeAggregates(a []string, b []string) []string {
+	m := make(map[string]bool)
+	r := make([]string, 0)
+	for _, s := range append(a, b...) {
+		s = strings.ReplaceAll(s, " ", "")
+		if !m[s] {
+			m[s] = true
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// ParseAggregate splits "sum(amount)" to "sum" and "amount"
+func ParseAggregate(s string) (string, string, error) {
+	i := strings.Index(s, "(")
+	if i <= 0 || !strings.HasSuffix(s, ")") {
+		return "", "", NewBadRequestError("invalid aggregate " + s)
+	}
+	f := strings.ToLower(strings.TrimSpace(s[0:i]))
+	if !aggregateFunctions[f] {
+		return "", "", NewBadRequestError("aggregate function " + f + " is not supported")
+	}
+	return f, strings.TrimSpace(s[i+1 : len(s)-1]), nil
+}
+func BuildAggregateQuery(sql string, aggregates []string, modelType reflect.Type) (string, error) {
+	exprs := make([]string, 0)
+	for i, a := range aggregates {
+		f, name, err := ParseAggregate(a)
+		if err != nil {
+			return "", err
+		}
+		column := "*"
+		if name != "*" {
+			index, _, col := GetFieldByJson(modelType, name)
+			if index < 0 || len(col) == 0 {
+				return "", NewUnknownFieldError(name)
+			}
+			column = col
+		} else if f != AggregateCount {
+			return "", NewBadRequestError("invalid aggregate " + a)
+		}
+		exprs = append(exprs, f+"("+column+") as a"+strconv.Itoa(i))
+	}
+	i := strings.Index(sql, "select ")
+	j := strings.Index(sql, " from ")
+	if i < 0 || j < 0 {
+		return "", errors.New("cannot build aggregate query")
+	}
+	k := strings.Index(sql, " order by ")
+	if k < 0 {
+		k = len(sql)
+	}
+	if strings.Index(sql, " distinct ") > 0 {
+		return "select " + strings.Join(exprs, ",") + " from (" + sql[i:k] + ") as main", nil
+	}
+	return "select " + strings.Join(exprs, ",") + sql[j:k], nil
+}
+func BuildAggregates(ctx context.Context, db Querier, query string, params []interface{}, aggregates []string, modelType reflect.Type) (map[string]interface{}, error) {
+	aggregateQuery, err := BuildAggregateQuery(query, aggregates, modelType)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(aggregates))
+	pointers := make([]interface{}, len(aggregates))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	row := GetQuerier(ctx, db).QueryRowContext(ctx, aggregateQuery, params...)
+	if err := row.Scan(pointers...); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	for i, a := range aggregates {
+		result[a] = ToNumber(values[i])
+	}
+	return result, nil
+}
+
+// ToNumber converts the numeric text returned by some drivers to float64
+func ToNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case []byte:
+		if f, err := strconv.ParseFloat(string(n), 64); err == nil {
+			return f
+		}
+		return string(n)
+	case string:
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			return f
+		}
+		return n
+	default:
+		return v
+	}
+}
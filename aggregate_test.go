@@ -0,0 +1,72 @@
+package search
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildAggregateQuery(t *testing.T) {
+	modelType := reflect.TypeOf(testUser{})
+	query, err := BuildAggregateQuery("select id,salary from users where status in (?) order by id", []string{"sum(salary)", "avg(salary)", "count(*)"}, modelType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "select sum(salary) as a0,avg(salary) as a1,count(*) as a2 from users where status in (?)"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	for _, a := range []string{"median(salary)", "sum(unknown)", "sum(*)", "salary"} {
+		if _, err := BuildAggregateQuery("select id from users", []string{a}, modelType); err == nil {
+			t.Errorf("%s must fail", a)
+		}
+	}
+}
+
+func TestGetAggregatesFromTag(t *testing.T) {
+	aggregates := MergeAggregates(GetAggregatesFromTag(reflect.TypeOf(testUser{})), []string{"sum( salary )", "max(salary)"})
+	if want := []string{"sum(salary)", "max(salary)"}; !reflect.DeepEqual(aggregates, want) {
+		t.Errorf("aggregates = %v, want %v", aggregates, want)
+	}
+}
+
+func TestAggregateInResult(t *testing.T) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		switch {
+		case strings.HasPrefix(query, "select sum("):
+			return rowsOf([]string{"a0", "a1"}, []driver.Value{[]byte("3000.5"), int64(7)}), nil
+		case isCountQuery(query):
+			return totalOf(2), nil
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john"), testUserRow("2", "joe")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(testUserSM{}), nil, nil)
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?status=A&aggregates=max(salary)", nil))
+	var result struct {
+		Total      int64              `json:"total"`
+		Aggregates map[string]float64 `json:"aggregates"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if want := map[string]float64{"sum(salary)": 3000.5, "max(salary)": 7}; !reflect.DeepEqual(result.Aggregates, want) {
+		t.Errorf("aggregates = %v, want %v", result.Aggregates, want)
+	}
+	var aggregateQuery *fakeQuery
+	for _, q := range f.Queries() {
+		if strings.HasPrefix(q.Query, "select sum(") {
+			q := q
+			aggregateQuery = &q
+		}
+	}
+	if aggregateQuery == nil || aggregateQuery.Query != "select sum(salary) as a0,max(salary) as a1 from users where status in (?)" || !reflect.DeepEqual(aggregateQuery.Args, []interface{}{"A"}) {
+		t.Errorf("aggregate query = %+v", aggregateQuery)
+	}
+}
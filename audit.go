@@ -0,0 +1,163 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Redacted = "***"
+
+type AuditEvent struct {
+	Time     time.Time              `mapstructure:"time" json:"time" gorm:"column:time" bson:"time" dynamodbav:"time" firestore:"time"`
+	Resource string                 `mapstructure:"resource" json:"resource,omitempty" gorm:"column:resource" bson:"resource,omitempty" dynamodbav:"resource,omitempty" firestore:"resource,omitempty"`
+	Action   string                 `mapstructure:"action" json:"action,omitempty" gorm:"column:action" bson:"action,omitempty" dynamodbav:"action,omitempty" firestore:"action,omitempty"`
+	UserId   string                 `mapstructure:"user_id" json:"userId,omitempty" gorm:"column:userid" bson:"userId,omitempty" dynamodbav:"userId,omitempty" firestore:"userId,omitempty"`
+	Filters  map[string]interface{} `mapstructure:"filters" json:"filters,omitempty" gorm:"column:filters" bson:"filters,omitempty" dynamodbav:"filters,omitempty" firestore:"filters,omitempty"`
+	Sort     string                 `mapstructure:"sort" json:"sort,omitempty" gorm:"column:sort" bson:"sort,omitempty" dynamodbav:"sort,omitempty" firestore:"sort,omitempty"`
+	Page     int64                  `mapstructure:"page" json:"page,omitempty" gorm:"column:page" bson:"page,omitempty" dynamodbav:"page,omitempty" firestore:"page,omitempty"`
+	Limit    int64                  `mapstructure:"limit" json:"limit,omitempty" gorm:"column:limit" bson:"limit,omitempty" dynamodbav:"limit,omitempty" firestore:"limit,omitempty"`
+	Count    int64                  `mapstructure:"count" json:"count" gorm:"column:count" bson:"count" dynamodbav:"count" firestore:"count"`
+	Total    int64                  `mapstructure:"total" json:"total" gorm:"column:total" bson:"total" dynamodbav:"total" firestore:"total"`
+	// in milliseconds
+	Duration int64  `mapstructure:"duration" json:"duration" gorm:"column:duration" bson:"duration" dynamodbav:"duration" firestore:"duration"`
+	Status   int    `mapstructure:"status" json:"status" gorm:"column:status" bson:"status" dynamodbav:"status" firestore:"status"`
+	Success  bool   `mapstructure:"success" json:"success" gorm:"column:success" bson:"success" dynamodbav:"success" firestore:"success"`
+	Error    string `mapstructure:"error" json:"error,omitempty" gorm:"column:error" bson:"error,omitempty" dynamodbav:"error,omitempty" firestore:"error,omitempty"`
+}
+
+// JSONLinesAuditor writes one json audit event per line
+type JSONLinesAuditor struct {
+	mu     sync.Mutex
+	Writer io.Writer
+}
+
+func NewJSONLinesAuditor(writer io.Writer) *JSONLinesAuditor {
+	return &JSONLinesAuditor{Writer: writer}
+}
+func (a *JSONLinesAuditor) Audit(ctx context.Context, event AuditEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.Writer.Write(append(b, '\n'))
+	return err
+}
+
+// NewLogAuditor sends the audit event to writeLog, with the event as json description
+func NewLogAuditor(writeLog func(ctx context.Context, resource string, action string, success bool, desc string) error) func(context.Context, AuditEvent) error {
+	return func(ctx context.Context, event AuditEvent) error {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return writeLog(ctx, event.Resource, event.Action, event.Success, string(b))
+	}
+}
+
+// NormalizeFilters returns the non empty filters of the search model by json names; the field with `audit:"redact"` is redacted, `audit:"-"` is skipped.
+// The keyword is free text, which may have personal data, so it is always redacted; the excluding values follow the tag of the field.
+func NormalizeFilters(searchModel interface{}) map[string]interface{} {
+	filters := make(map[string]interface{})
+	value := reflect.Indirect(reflect.ValueOf(searchModel))
+	if value.Kind() != reflect.Struct {
+		return filters
+	}
+	t := value.Type()
+	if sm := GetSearchModel(searchModel); sm != nil {
+		if len(sm.Keyword) > 0 {
+			filters["keyword"] = Redacted
+		}
+		if len(sm.Excluding) > 0 {
+			excluding := make(map[string]interface{})
+			for key, values := range sm.Excluding {
+				switch getAuditTag(t, key) {
+				case "-":
+				case "redact":
+					excluding[key] = Redacted
+				default:
+					excluding[key] = values
+				}
+			}
+			if len(excluding) > 0 {
+				filters["excluding"] = excluding
+			}
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := value.Field(i)
+		if len(sf.PkgPath) > 0 || sf.Type == reflect.TypeOf(&SearchModel{}) || sf.Type == reflect.TypeOf(SearchModel{}) {
+			continue
+		}
+		tag := sf.Tag.Get("audit")
+		if tag == "-" || field.IsZero() {
+			continue
+		}
+		if (field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() == 0 {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if len(name) == 0 || name == "-" {
+			name = sf.Name
+		}
+		if tag == "redact" {
+			filters[name] = Redacted
+		} else {
+			filters[name] = reflect.Indirect(field).Interface()
+		}
+	}
+	return filters
+}
+func getAuditTag(searchModelType reflect.Type, jsonName string) string {
+	if i, _ := findIndexByTagJson(searchModelType, jsonName); i >= 0 {
+		return searchModelType.Field(i).Tag.Get("audit")
+	}
+	return ""
+}
+func GetResultCount(results interface{}) int64 {
+	if results == nil {
+		return 0
+	}
+	v := reflect.Indirect(reflect.ValueOf(results))
+	if v.Kind() == reflect.Ptr {
+		v = reflect.Indirect(v)
+	}
+	if v.Kind() == reflect.Slice {
+		return int64(v.Len())
+	}
+	return 0
+}
+
+func (c *SearchHandler) audit(r *http.Request, start time.Time, searchModel interface{}, results interface{}, total int64, w *responseWriter) {
+	event := AuditEvent{Time: start, Resource: c.Resource, Action: c.Action, Duration: time.Since(start).Milliseconds(), Status: w.status, Total: total, Count: GetResultCount(results)}
+	if event.Status == 0 {
+		event.Status = http.StatusOK
+	}
+	if searchModel != nil {
+		event.Filters = NormalizeFilters(searchModel)
+		if sm := GetSearchModel(searchModel); sm != nil {
+			event.UserId = sm.CurrentUserId
+			event.Sort = sm.Sort
+			event.Page = sm.Page
+			event.Limit = sm.Limit
+		}
+	}
+	if len(event.UserId) == 0 {
+		event.UserId = GetUserId(r, c.userId)
+	}
+	if w.err != nil {
+		event.Error = ToProblem(w.err).Type
+	}
+	event.Success = w.err == nil && event.Status < http.StatusBadRequest
+	if err := c.Audit(r.Context(), event); err != nil && c.Error != nil {
+		c.Error(r.Context(), "cannot write audit event: "+err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type customerSM struct {
+	*SearchModel
+	Status []string `json:"status"`
+	Phone  string   `json:"phone" audit:"redact"`
+	Token  string   `json:"token" audit:"-"`
+	Email  string   `json:"email" audit:"redact"`
+}
+
+func TestAuditEvent(t *testing.T) {
+	builder, _ := newUserDB(t, 25)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(customerSM{}), nil, nil)
+	var buf bytes.Buffer
+	h.Audit = NewJSONLinesAuditor(&buf).Audit
+	r := httptest.NewRequest(http.MethodGet, "/customers?status=A&phone=0123&token=x&keyword=john&sort=-id&page=2&limit=10", nil)
+	r = r.WithContext(context.WithValue(r.Context(), UserId, "u1"))
+	h.Search(httptest.NewRecorder(), r)
+	h.Search(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/customers?sort=unknown", nil))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("audit lines = %q", buf.String())
+	}
+	var event AuditEvent
+	if err := json.Unmarshal(lines[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Resource != "customer" || event.Action != Search || event.UserId != "u1" || event.Sort != "-id" || event.Page != 2 || event.Limit != 10 || event.Count != 2 || event.Total != 25 || !event.Success || event.Status != http.StatusOK {
+		t.Errorf("event = %+v", event)
+	}
+	if want := map[string]interface{}{"status": []interface{}{"A"}, "phone": Redacted, "keyword": Redacted}; !reflect.DeepEqual(event.Filters, want) {
+		t.Errorf("filters = %v, want %v", event.Filters, want)
+	}
+	if err := json.Unmarshal(lines[1], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Success || event.Status != http.StatusBadRequest || event.Error != ProblemInvalidSort {
+		t.Errorf("failed event = %+v", event)
+	}
+}
+
+func TestNormalizeFiltersRedactsExcluding(t *testing.T) {
+	sm := &customerSM{SearchModel: &SearchModel{Keyword: "0123 456", Excluding: map[string][]interface{}{"status": {"I"}, "email": {"a@test.com"}, "token": {"x"}}}}
+	want := map[string]interface{}{"keyword": Redacted, "excluding": map[string]interface{}{"status": []interface{}{"I"}, "email": Redacted}}
+	if filters := NormalizeFilters(sm); !reflect.DeepEqual(filters, want) {
+		t.Errorf("filters = %v, want %v", filters, want)
+	}
+}
+
+func TestLogAuditor(t *testing.T) {
+	var resource, desc string
+	var success bool
+	audit := NewLogAuditor(func(ctx context.Context, r string, action string, s bool, d string) error {
+		resource, success, desc = r, s, d
+		return nil
+	})
+	if err := audit(context.Background(), AuditEvent{Resource: "customer", Success: true, Total: 3}); err != nil {
+		t.Fatal(err)
+	}
+	var event AuditEvent
+	if err := json.Unmarshal([]byte(desc), &event); err != nil || resource != "customer" || !success || event.Total != 3 {
+		t.Errorf("resource %q, success %v, desc %q", resource, success, desc)
+	}
+}
@@ -0,0 +1,328 @@
+package search
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchCache caches the results of the searches by the canonical hash of the search model, with TTL and LRU eviction.
+// Concurrent identical searches share one query.
+type SearchCache struct {
+	TTL     time.Duration
+	MaxSize int
+	// if not empty, it is used instead of the resource of the context
+	Resource string
+	// adds the user or the tenant to the key, such as ByTenant; if nil, ByUser is used. SharedScope shares the results by all users
+	Scope   func(ctx context.Context, searchModel interface{}) (string, error)
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List
+	calls   map[string]*cacheCall
+	version map[string]int64
+}
+type cacheEntry struct {
+	key      string
+	resource string
+	models   interface{}
+	total    int64
+	info     TotalInfo
+	expiry   time.Time
+}
+type cacheCall struct {
+	wg     sync.WaitGroup
+	models interface{}
+	total  int64
+	info   TotalInfo
+	err    error
+}
+
+func NewSearchCache(ttl time.Duration, maxSize int, options ...func(context.Context, interface{}) (string, error)) *SearchCache {
+	scope := ByUser
+	if len(options) >= 1 && options[0] != nil {
+		scope = options[0]
+	}
+	return &SearchCache{TTL: ttl, MaxSize: maxSize, Scope: scope, items: make(map[string]*list.Element), lru: list.New(), calls: make(map[string]*cacheCall), version: make(map[string]int64)}
+}
+
+// ByUser scopes the cache by the current user of the search model
+func ByUser(ctx context.Context, searchModel interface{}) (string, error) {
+	if sm := GetSearchModel(searchModel); sm != nil {
+		return sm.CurrentUserId, nil
+	}
+	return "", nil
+}
+
+// SharedScope shares the cached results by all users, for the resources without user filters or field policies
+func SharedScope(ctx context.Context, searchModel interface{}) (string, error) {
+	return "", nil
+}
+
+// ByTenant scopes the cache by the tenant of the context
+func ByTenant(tenant *TenantConfig) func(context.Context, interface{}) (string, error) {
+	return func(ctx context.Context, searchModel interface{}) (string, error) {
+		return tenant.Resolve(ctx)
+	}
+}
+
+// Middleware returns the cached results, or searches once for all concurrent identical searches; errors are not cached.
+// The cached slice is copied for each caller, so that AfterSearch hooks, such as FieldPolicy, can modify the results.
+// The hidden fields of the context, such as the ones of FieldPolicy, are added to the key.
+// The TotalInfo of the search, such as the unknown total of CountConfig, is cached with the results and copied to the one of the context.
+func (c *SearchCache) Middleware(next SearchService) SearchService {
+	return SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		resource := c.Resource
+		if len(resource) == 0 {
+			resource = GetResource(ctx)
+		}
+		getScope := c.Scope
+		if getScope == nil {
+			getScope = ByUser
+		}
+		scope, err := getScope(ctx, m)
+		if err != nil {
+			return nil, 0, err
+		}
+		if hidden := GetHiddenFields(ctx); len(hidden) > 0 {
+			// the results of the users, who cannot see the same fields, must not share the key
+			names := make([]string, 0, len(hidden))
+			for name := range hidden {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			scope = scope + "\x00hidden:" + strings.Join(names, ",")
+		}
+		if IsCountOnly(ctx) {
+			// the count only search has no results, so it must not share the key of the search
+			scope = scope + "\x00count"
+		}
+		key, err := CacheKey(resource, scope, m)
+		if err != nil {
+			return next.Search(ctx, m)
+		}
+		info := GetTotalInfo(ctx)
+		c.mu.Lock()
+		if e, ok := c.items[key]; ok {
+			entry := e.Value.(*cacheEntry)
+			if time.Now().Before(entry.expiry) {
+				c.lru.MoveToFront(e)
+				c.mu.Unlock()
+				if info != nil {
+					*info = entry.info
+				}
+				return CopyResults(entry.models), entry.total, nil
+			}
+			c.remove(e)
+		}
+		if call, ok := c.calls[key]; ok {
+			c.mu.Unlock()
+			call.wg.Wait()
+			if info != nil && call.err == nil {
+				*info = call.info
+			}
+			return CopyResults(call.models), call.total, call.err
+		}
+		call := &cacheCall{}
+		call.wg.Add(1)
+		c.calls[key] = call
+		version := c.version[resource]
+		c.mu.Unlock()
+
+		call.info = TotalInfo{Exact: true}
+		if info != nil {
+			call.info = *info
+		}
+		func() {
+			defer func() {
+				if v := recover(); v != nil {
+					call.err = RecoverToError(v)
+				}
+			}()
+			call.models, call.total, call.err = next.Search(WithTotalInfo(ctx, &call.info), m)
+		}()
+		if info != nil {
+			*info = call.info
+		}
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.err == nil && version == c.version[resource] {
+			c.add(&cacheEntry{key: key, resource: resource, models: call.models, total: call.total, info: call.info, expiry: time.Now().Add(c.TTL)})
+		}
+		c.mu.Unlock()
+		call.wg.Done()
+		return CopyResults(call.models), call.total, call.err
+	})
+}
+
+// Invalidate removes the cached results of the resource, and keeps the running searches of the resource from being cached
+func (c *SearchCache) Invalidate(resource string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version[resource]++
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*cacheEntry).resource == resource {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+func (c *SearchCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for resource := range c.version {
+		c.version[resource]++
+	}
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+}
+func (c *SearchCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+func (c *SearchCache) add(entry *cacheEntry) {
+	if e, ok := c.items[entry.key]; ok {
+		c.remove(e)
+	}
+	c.items[entry.key] = c.lru.PushFront(entry)
+	for c.MaxSize > 0 && c.lru.Len() > c.MaxSize {
+		c.remove(c.lru.Back())
+	}
+}
+func (c *SearchCache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.items, e.Value.(*cacheEntry).key)
+}
+
+// CopyResults returns the shallow copy of the slice or the pointer to slice
+func CopyResults(results interface{}) interface{} {
+	if results == nil {
+		return nil
+	}
+	v := reflect.ValueOf(results)
+	isPtr := v.Kind() == reflect.Ptr
+	if isPtr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice || v.IsNil() {
+		return results
+	}
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+	if !isPtr {
+		return c.Interface()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(c)
+	return p.Interface()
+}
+
+// CacheKey returns the sha256 of the resource, the scope and the canonical json of the search model
+func CacheKey(resource string, scope string, searchModel interface{}) (string, error) {
+	b, err := json.Marshal(Canonical(searchModel))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(resource))
+	h.Write([]byte{0})
+	h.Write([]byte(scope))
+	h.Write([]byte{0})
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Canonical converts the search model to maps by json names without the empty fields, the current user, dates in UTC,
+// and the sorted lists of scalars, such as fields and "in" filters.
+// The pointer to the zero value, such as the filter active=false, is kept, because it is not the same as no filter.
+func Canonical(v interface{}) interface{} {
+	return canonical(reflect.ValueOf(v))
+}
+func canonical(v reflect.Value) interface{} {
+	isPtr := false
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		isPtr = isPtr || v.Kind() == reflect.Ptr
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if len(sf.PkgPath) > 0 || sf.Name == "CurrentUserId" {
+				continue
+			}
+			name := strings.Split(sf.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				name = sf.Name
+			}
+			if x := canonical(v.Field(i)); x != nil {
+				m[name] = x
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		m := make(map[string]interface{})
+		iter := v.MapRange()
+		for iter.Next() {
+			if x := canonical(iter.Value()); x != nil {
+				m[fmt.Sprint(iter.Key().Interface())] = x
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return nil
+		}
+		a := make([]interface{}, 0, v.Len())
+		scalar := true
+		for i := 0; i < v.Len(); i++ {
+			x := canonical(v.Index(i))
+			switch x.(type) {
+			case map[string]interface{}, []interface{}:
+				scalar = false
+			}
+			a = append(a, x)
+		}
+		if scalar {
+			sort.Slice(a, func(i, j int) bool { return fmt.Sprint(a[i]) < fmt.Sprint(a[j]) })
+		}
+		return a
+	default:
+		if v.IsZero() && !isPtr {
+			return nil
+		}
+		return v.Interface()
+	}
+}
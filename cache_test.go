@@ -0,0 +1,161 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type activeSM struct {
+	*SearchModel
+	Active *bool    `json:"active,omitempty"`
+	Status []string `json:"status,omitempty"`
+}
+
+func countingSearch(n *int32) SearchFunc {
+	return func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		atomic.AddInt32(n, 1)
+		return &[]testUser{{Id: "1"}}, 1, nil
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	inactive := false
+	none, _ := CacheKey("users", "", &activeSM{SearchModel: &SearchModel{Limit: 10}})
+	zero, _ := CacheKey("users", "", &activeSM{SearchModel: &SearchModel{Limit: 10}, Active: &inactive})
+	if none == zero {
+		t.Error("active=false must not have the key of no filter")
+	}
+	a, _ := CacheKey("users", "", &activeSM{SearchModel: &SearchModel{Limit: 10, Fields: []string{"id", "email"}}, Status: []string{"I", "A"}})
+	b, _ := CacheKey("users", "", &activeSM{SearchModel: &SearchModel{Limit: 10, Fields: []string{"email", "id"}}, Status: []string{"A", "I"}})
+	if a != b {
+		t.Error("the order of fields and filters must not change the key")
+	}
+}
+
+func TestCacheScopesByUser(t *testing.T) {
+	var n int32
+	search := NewSearchCache(time.Minute, 10).Middleware(countingSearch(&n))
+	for _, user := range []string{"u1", "u2", "u1"} {
+		if _, _, err := search.Search(context.Background(), &activeSM{SearchModel: &SearchModel{Limit: 10, CurrentUserId: user}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 2 {
+		t.Errorf("searches = %d, want one per user", n)
+	}
+
+	n = 0
+	shared := NewSearchCache(time.Minute, 10, SharedScope).Middleware(countingSearch(&n))
+	for _, user := range []string{"u1", "u2"} {
+		shared.Search(context.Background(), &activeSM{SearchModel: &SearchModel{Limit: 10, CurrentUserId: user}})
+	}
+	if n != 1 {
+		t.Errorf("shared searches = %d, want 1", n)
+	}
+}
+
+func TestCacheKeepsTotalInfo(t *testing.T) {
+	search := NewSearchCache(time.Minute, 10).Middleware(SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		if info := GetTotalInfo(ctx); info != nil {
+			info.Exact, info.Unknown, info.HasNext = false, true, true
+		}
+		return &[]testUser{{Id: "1"}}, -1, nil
+	}))
+	sm := &activeSM{SearchModel: &SearchModel{Limit: 10}}
+	for i := 0; i < 2; i++ {
+		info := &TotalInfo{Exact: true}
+		_, total, err := search.Search(WithTotalInfo(context.Background(), info), sm)
+		if err != nil || total != -1 {
+			t.Fatal(total, err)
+		}
+		if info.Exact || !info.Unknown || !info.HasNext {
+			t.Errorf("search %d: info = %+v", i, info)
+		}
+	}
+}
+
+func TestCacheSharesConcurrentSearch(t *testing.T) {
+	var n int32
+	release := make(chan struct{})
+	search := NewSearchCache(time.Minute, 10).Middleware(SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		atomic.AddInt32(&n, 1)
+		<-release
+		return &[]testUser{{Id: "1"}}, 1, nil
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, total, err := search.Search(context.Background(), &activeSM{SearchModel: &SearchModel{Limit: 10}})
+			if err != nil || total != 1 || len(*results.(*[]testUser)) != 1 {
+				t.Error(results, total, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n != 1 {
+		t.Errorf("searches = %d, want 1", n)
+	}
+}
+
+func TestCacheSeparatesCountOnly(t *testing.T) {
+	var n int32
+	search := NewSearchCache(time.Minute, 10).Middleware(SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		atomic.AddInt32(&n, 1)
+		if IsCountOnly(ctx) {
+			return nil, 1, nil
+		}
+		return &[]testUser{{Id: "1"}}, 1, nil
+	}))
+	sm := &activeSM{SearchModel: &SearchModel{Limit: 10}}
+	search.Search(WithCountOnly(context.Background()), sm)
+	results, _, _ := search.Search(context.Background(), sm)
+	if n != 2 || results == nil {
+		t.Errorf("the count only search must not be served as the search: %d %v", n, results)
+	}
+}
+
+func TestCacheSeparatesHiddenFields(t *testing.T) {
+	h, f := newEmployeeHandler(t)
+	h.Use(NewSearchCache(time.Minute, 10, SharedScope).Middleware)
+	searchAs(h, "staff", http.MethodPost, "/employees/search", `{"name":"jo"}`)
+	w := searchAs(h, "hr", http.MethodPost, "/employees/search", `{"name":"jo"}`)
+	if len(f.Queries()) != 3 || !strings.Contains(w.Body.String(), `"salary":5000`) {
+		t.Errorf("the results of the hidden fields must not be shared: %d queries, %s", len(f.Queries()), w.Body.String())
+	}
+	searchAs(h, "staff", http.MethodPost, "/employees/search", `{"name":"jo"}`)
+	if len(f.Queries()) != 3 {
+		t.Errorf("the same hidden fields must share the key: %d queries", len(f.Queries()))
+	}
+}
+
+func TestCacheEvictsAndInvalidates(t *testing.T) {
+	var n int32
+	cache := NewSearchCache(time.Minute, 2)
+	search := cache.Middleware(countingSearch(&n))
+	ctx := WithResource(context.Background(), "users")
+	for _, s := range []string{"A", "I", "D"} {
+		search.Search(ctx, &activeSM{SearchModel: &SearchModel{Limit: 10}, Status: []string{s}})
+	}
+	if cache.Len() != 2 {
+		t.Errorf("len = %d, want 2", cache.Len())
+	}
+	cache.Invalidate("users")
+	if cache.Len() != 0 {
+		t.Errorf("len = %d after invalidate", cache.Len())
+	}
+	results, _, _ := search.Search(ctx, &activeSM{SearchModel: &SearchModel{Limit: 10}, Status: []string{"A"}})
+	copied, _, _ := search.Search(ctx, &activeSM{SearchModel: &SearchModel{Limit: 10}, Status: []string{"A"}})
+	(*copied.(*[]testUser))[0].Id = "changed"
+	if (*results.(*[]testUser))[0].Id != "1" || n != 4 {
+		t.Errorf("the cached results must be copied for each caller: %v, searches %d", *results.(*[]testUser), n)
+	}
+}
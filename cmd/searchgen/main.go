@@ -0,0 +1,822 @@
+// Command searchgen generates the query builders and the row scanners of the search models without reflection.
+//
+// The search model is the struct, which embeds SearchModel; the model is the struct of the same name without "SM" or "Filter" suffix,
+// or the one given by -type, such as -type UserSM=User,RoleFilter=Role.
+//
+//	//go:generate searchgen -type UserSM=User
+//
+// For each pair, it generates Build<SM>Query, New<SM>QueryBuilder for search.NewSearchBuilderWithMap,
+// Scan<Model> and New<SM>Search for search.NewSearcher.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/common-go/search"
+)
+
+const (
+	kindSearchModel = iota
+	kindString
+	kindSlice
+	kindDateRange
+	kindTimeRange
+	kindNumberRange
+	kindExact
+)
+
+var keywordFormat = map[string]string{
+	"prefix":  "?%",
+	"contain": "%?%",
+	"equal":   "?",
+}
+
+type Field struct {
+	Name     string
+	Kind     int
+	Ptr      bool
+	Embedded bool
+	Tag      reflect.StructTag
+}
+type Struct struct {
+	Name   string
+	Fields []Field
+}
+
+// IsSearchModel returns true if the struct embeds SearchModel
+func (s *Struct) IsSearchModel() bool {
+	for _, f := range s.Fields {
+		if f.Kind == kindSearchModel {
+			return true
+		}
+	}
+	return false
+}
+func (s *Struct) Field(name string) (Field, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// HasKeyword returns true if any string field has keyword tag
+func HasKeyword(s *Struct) bool {
+	for _, f := range s.Fields {
+		if _, ok := f.Tag.Lookup("keyword"); ok && f.Kind == kindString && !f.Embedded {
+			return true
+		}
+	}
+	return false
+}
+
+// Columns returns the gorm columns, like search.GetColumnsSelect
+func (s *Struct) Columns() []string {
+	columns := make([]string, 0)
+	for _, f := range s.Fields {
+		if column, ok := search.FindTag(f.Tag.Get("gorm"), "column"); ok {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// JsonColumns returns the columns by json names of the first fields, like search.GetFieldByJson
+func (s *Struct) JsonColumns() ([]string, map[string]string) {
+	names := make([]string, 0)
+	columns := make(map[string]string)
+	for _, f := range s.Fields {
+		tag, ok := f.Tag.Lookup("json")
+		if !ok {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if _, exist := columns[name]; exist {
+			continue
+		}
+		column, _ := search.FindTag(f.Tag.Get("gorm"), "column")
+		names = append(names, name)
+		columns[name] = column
+	}
+	return names, columns
+}
+
+// ColumnName returns the column of the field, like search.GetColumnName
+func (s *Struct) ColumnName(name string) (string, bool) {
+	f, ok := s.Field(name)
+	if !ok {
+		return name, false
+	}
+	tag, ok := f.Tag.Lookup("gorm")
+	if !ok {
+		return "", true
+	}
+	if column, ok := search.FindTag(tag, "column"); ok {
+		return column, true
+	}
+	return name, false
+}
+
+func main() {
+	dir := flag.String("dir", ".", "the directory of the search models and the models")
+	types := flag.String("type", "", "the pairs of search model and model, such as UserSM=User,RoleFilter=Role")
+	output := flag.String("output", "search_gen.go", "the generated file, in the directory")
+	flag.Parse()
+
+	pkg, structs, err := ParseDir(*dir, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pairs, err := GetPairs(structs, *types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(pairs) == 0 {
+		log.Fatal("no search model is found")
+	}
+	src, err := Generate(pkg, structs, pairs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ParseDir parses the go files of the directory, except the tests and the output
+func ParseDir(dir string, output string) (string, map[string]*Struct, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	var pkg string
+	structs := make(map[string]*Struct)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") || filepath.Base(file) == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		pkg = f.Name.Name
+		searchImport := GetSearchImport(f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			s := &Struct{Name: spec.Name.Name}
+			for _, field := range st.Fields.List {
+				var tag reflect.StructTag
+				if field.Tag != nil {
+					if t, err := strconv.Unquote(field.Tag.Value); err == nil {
+						tag = reflect.StructTag(t)
+					}
+				}
+				kind, ptr := GetKind(field.Type, searchImport, pkg == "search")
+				if len(field.Names) == 0 {
+					s.Fields = append(s.Fields, Field{Name: GetTypeName(field.Type), Kind: kind, Ptr: ptr, Embedded: true, Tag: tag})
+				}
+				for _, name := range field.Names {
+					s.Fields = append(s.Fields, Field{Name: name.Name, Kind: kind, Ptr: ptr, Tag: tag})
+				}
+			}
+			structs[s.Name] = s
+			return false
+		})
+	}
+	return pkg, structs, nil
+}
+
+// GetSearchImport returns the name of github.com/common-go/search in the file
+func GetSearchImport(f *ast.File) string {
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		if path == "github.com/common-go/search" {
+			if spec.Name != nil {
+				return spec.Name.Name
+			}
+			return "search"
+		}
+	}
+	return ""
+}
+func GetTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return GetTypeName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	default:
+		return ""
+	}
+}
+func GetKind(expr ast.Expr, searchImport string, inSearch bool) (int, bool) {
+	ptr := false
+	if star, ok := expr.(*ast.StarExpr); ok {
+		ptr = true
+		expr = star.X
+	}
+	name := ""
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && len(searchImport) > 0 && x.Name == searchImport {
+			name = t.Sel.Name
+		}
+	case *ast.Ident:
+		if inSearch {
+			name = t.Name
+		} else if t.Name == "string" {
+			return kindString, ptr
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return kindSlice, ptr
+		}
+	}
+	switch name {
+	case "SearchModel":
+		return kindSearchModel, ptr
+	case "DateRange":
+		return kindDateRange, ptr
+	case "TimeRange":
+		return kindTimeRange, ptr
+	case "NumberRange":
+		return kindNumberRange, ptr
+	case "string":
+		return kindString, ptr
+	}
+	return kindExact, ptr
+}
+
+// GetPairs returns the pairs of the search models and the models, by types or by the names without "SM" or "Filter" suffix
+func GetPairs(structs map[string]*Struct, types string) ([][2]string, error) {
+	pairs := make([][2]string, 0)
+	if len(types) > 0 {
+		for _, pair := range strings.Split(types, ",") {
+			names := strings.Split(strings.TrimSpace(pair), "=")
+			if len(names) != 2 {
+				return nil, fmt.Errorf("invalid type %s, it must be SearchModel=Model", pair)
+			}
+			sm, ok := structs[names[0]]
+			if !ok || !sm.IsSearchModel() {
+				return nil, fmt.Errorf("%s is not a search model", names[0])
+			}
+			if _, ok := structs[names[1]]; !ok {
+				return nil, fmt.Errorf("model %s is not found", names[1])
+			}
+			pairs = append(pairs, [2]string{names[0], names[1]})
+		}
+		return pairs, nil
+	}
+	names := make([]string, 0)
+	for name := range structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !structs[name].IsSearchModel() {
+			continue
+		}
+		for _, suffix := range []string{"SM", "Filter"} {
+			if model := strings.TrimSuffix(name, suffix); model != name {
+				if _, ok := structs[model]; ok {
+					pairs = append(pairs, [2]string{name, model})
+					break
+				}
+			}
+		}
+	}
+	return pairs, nil
+}
+
+type generator struct {
+	buf       bytes.Buffer
+	q         string
+	needTime  bool
+	generated map[string]bool
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// Generate generates the query builders, the scanners and the search funcs of the pairs
+func Generate(pkg string, structs map[string]*Struct, pairs [][2]string) ([]byte, error) {
+	g := &generator{q: "search.", generated: make(map[string]bool)}
+	if pkg == "search" {
+		g.q = ""
+	}
+	var body bytes.Buffer
+	for _, pair := range pairs {
+		g.buf.Reset()
+		if err := g.generate(structs[pair[0]], structs[pair[1]]); err != nil {
+			return nil, err
+		}
+		body.Write(g.buf.Bytes())
+	}
+	g.buf.Reset()
+	g.p("// Code generated by searchgen. DO NOT EDIT.")
+	g.p("")
+	g.p("package %s", pkg)
+	g.p("")
+	g.p("import (")
+	g.p(`"context"`)
+	g.p(`"database/sql"`)
+	g.p(`"fmt"`)
+	g.p(`"strings"`)
+	if g.needTime {
+		g.p(`"time"`)
+	}
+	if len(g.q) > 0 {
+		g.p("")
+		g.p(`"github.com/common-go/search"`)
+	}
+	g.p(")")
+	g.buf.Write(body.Bytes())
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return g.buf.Bytes(), err
+	}
+	return src, nil
+}
+func lowerFirst(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return strings.ToLower(s[0:1]) + s[1:]
+}
+
+func (g *generator) generate(sm *Struct, model *Struct) error {
+	q := g.q
+	modelVar := lowerFirst(model.Name)
+	if !g.generated[model.Name] {
+		g.generated[model.Name] = true
+		g.generateModel(model)
+	}
+	smVar := lowerFirst(sm.Name)
+	smNames, smColumns := sm.JsonColumns()
+	g.p("")
+	g.p("var %sJsonColumns = map[string]string{", smVar)
+	for _, name := range smNames {
+		g.p("%q: %q,", name, smColumns[name])
+	}
+	g.p("}")
+	g.p("")
+	g.p("// Build%sQuery builds the query of %s like %sBuildQuery, without reflection; the invalid sort and excluding fields are skipped, Check%s returns them as error", sm.Name, sm.Name, q, sm.Name)
+	g.p("func Build%sQuery(sm *%s, tableName string, driver string) (string, []interface{}) {", sm.Name, sm.Name)
+	g.p(`s1 := ""`)
+	g.p("rawConditions := make([]string, 0)")
+	g.p("keywordColumns := make([]string, 0)")
+	g.p("keywordValues := make([]interface{}, 0)")
+	g.p("queryValues := make([]interface{}, 0)")
+	g.p(`sortString := ""`)
+	g.p("var keyword string")
+	g.p("marker := 0")
+	if !HasKeyword(sm) {
+		g.p("_ = keyword")
+	}
+	for _, f := range sm.Fields {
+		if f.Kind == kindSearchModel {
+			if !f.Embedded {
+				continue
+			}
+			if f.Ptr {
+				g.p("if v := sm.%s; v != nil {", f.Name)
+			} else {
+				g.p("if v := &sm.%s; v != nil {", f.Name)
+			}
+			g.p("fields := make([]string, 0)")
+			g.p("for _, key := range v.Fields {")
+			g.p("if column, ok := %sJsonColumns[key]; ok {", modelVar)
+			g.p("fields = append(fields, column)")
+			g.p("} else {")
+			g.p("fields = append(fields, strings.ToLower(key))")
+			g.p("}")
+			g.p("}")
+			g.p("if len(fields) > 0 {")
+			g.p("s1 = `select ` + strings.Join(fields, \",\") + ` from ` + tableName")
+			g.p("} else {")
+			if columns := model.Columns(); len(columns) > 0 {
+				g.p("s1 = `select  %s from ` + tableName", strings.Join(columns, ","))
+			} else {
+				g.p("s1 = `select * from ` + tableName")
+			}
+			g.p("}")
+			g.p("if len(v.Sort) > 0 {")
+			g.p("sortString = build%sSort(v.Sort)", model.Name)
+			g.p("}")
+			g.p("if len(v.Excluding) > 0 {")
+			g.p("for key, val := range v.Excluding {")
+			g.p("columnName, ok := %sJsonColumns[key]", smVar)
+			g.p(`if !ok || columnName == "" {`)
+			g.p("continue")
+			g.p("}")
+			g.p("if len(val) > 0 {")
+			g.p(`format := fmt.Sprintf("(%%s)", %sBuildParametersFrom(marker, len(val), driver))`, q)
+			g.p("marker += len(val)")
+			g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s NOT IN %%s", columnName, format))`)
+			g.p("queryValues = append(queryValues, val...)")
+			g.p("}")
+			g.p("}")
+			g.p("} else if len(v.Keyword) > 0 {")
+			g.p("keyword = strings.TrimSpace(v.Keyword)")
+			g.p("}")
+			g.p("}")
+			continue
+		}
+		if f.Embedded {
+			log.Printf("%s.%s is skipped: embedded struct is not supported", sm.Name, f.Name)
+			continue
+		}
+		if !ast.IsExported(f.Name) {
+			log.Printf("%s.%s is skipped: unexported field", sm.Name, f.Name)
+			continue
+		}
+		columnName, exist := sm.ColumnName(f.Name)
+		if !exist {
+			columnName, _ = model.ColumnName(f.Name)
+		}
+		if properties := f.Tag.Get("sql_builder"); len(properties) > 0 {
+			for _, property := range strings.Split(properties, ";") {
+				if strings.HasPrefix(property, "column:") {
+					columnName = property[7:]
+					break
+				}
+			}
+		}
+		if len(columnName) == 0 {
+			log.Printf("%s.%s has no column: add gorm or sql_builder column tag", sm.Name, f.Name)
+		}
+		x := "sm." + f.Name
+		if f.Ptr {
+			g.p("if %s != nil {", x)
+		}
+		if err := g.generateField(sm, f, x, columnName); err != nil {
+			return err
+		}
+		if f.Ptr {
+			g.p("}")
+		}
+	}
+	// the keyword params are numbered and added after the params of the other conditions, like search.BuildQuery
+	g.p("if len(keywordColumns) > 0 {")
+	g.p("keywordConditions := make([]string, 0)")
+	g.p("for i, columnName := range keywordColumns {")
+	g.p("if driver == %sDriverPostgres {", q)
+	g.p(`keywordConditions = append(keywordConditions, fmt.Sprintf("%%s %%s %%s", columnName, "ilike", %sBuildParam(marker+1, driver)))`, q)
+	g.p("} else {")
+	g.p(`keywordConditions = append(keywordConditions, fmt.Sprintf("%%s %%s %%s", columnName, %sLike, %sBuildParam(marker+1, driver)))`, q, q)
+	g.p("}")
+	g.p("queryValues = append(queryValues, keywordValues[i])")
+	g.p("marker++")
+	g.p("}")
+	g.p(`rawConditions = append(rawConditions, "("+strings.Join(keywordConditions, " OR ")+")")`)
+	g.p("}")
+	g.p("if len(rawConditions) > 0 {")
+	g.p("return s1 + ` where ` + strings.Join(rawConditions, \" AND \") + sortString, queryValues")
+	g.p("}")
+	g.p("return s1 + sortString, queryValues")
+	g.p("}")
+
+	g.p("")
+	g.p("// Check%s returns the invalid sort and excluding fields as error like %sCheckSearchModel, without reflection", sm.Name, q)
+	g.p("func Check%s(sm *%s) error {", sm.Name, sm.Name)
+	for _, f := range sm.Fields {
+		if f.Kind == kindSearchModel && f.Embedded {
+			if f.Ptr {
+				g.p("if v := sm.%s; v != nil {", f.Name)
+			} else {
+				g.p("if v := &sm.%s; v != nil {", f.Name)
+			}
+			g.p("if err := check%sSort(v.Sort); err != nil {", model.Name)
+			g.p("return err")
+			g.p("}")
+			g.p("for key := range v.Excluding {")
+			g.p(`if columnName, ok := %sJsonColumns[key]; !ok || columnName == "" {`, smVar)
+			g.p("return %sNewUnknownFieldError(key)", q)
+			g.p("}")
+			g.p("}")
+			g.p("}")
+			break
+		}
+	}
+	g.p("return nil")
+	g.p("}")
+	g.p("")
+	g.p("// New%sQueryBuilder returns the build query func for %sNewSearchBuilderWithMap", sm.Name, q)
+	g.p("func New%sQueryBuilder(tableName string, driver string) func(interface{}) (string, []interface{}) {", sm.Name)
+	g.p("return func(m interface{}) (string, []interface{}) {")
+	g.p("return Build%sQuery(m.(*%s), tableName, driver)", sm.Name, sm.Name)
+	g.p("}")
+	g.p("}")
+
+	g.p("")
+	g.p("// New%sSearch returns the search func for %sNewSearcher, which returns *[]%s", sm.Name, q, model.Name)
+	g.p("func New%sSearch(db %sQuerier, tableName string) func(context.Context, interface{}) (interface{}, int64, error) {", sm.Name, q)
+	g.p("driver := %sGetDriver(db)", q)
+	g.p("return func(ctx context.Context, m interface{}) (interface{}, int64, error) {")
+	g.p("sm, ok := m.(*%s)", sm.Name)
+	g.p("if !ok {")
+	g.p(`return nil, 0, fmt.Errorf("expected *%s, got %%T", m)`, sm.Name)
+	g.p("}")
+	g.p("if err := Check%s(sm); err != nil {", sm.Name)
+	g.p("return nil, 0, err")
+	g.p("}")
+	g.p("query, params := Build%sQuery(sm, tableName, driver)", sm.Name)
+	g.p("var page, limit, firstLimit int64")
+	for _, f := range sm.Fields {
+		if f.Kind == kindSearchModel && f.Embedded {
+			if f.Ptr {
+				g.p("if sm.%s != nil {", f.Name)
+			} else {
+				g.p("{")
+			}
+			g.p("page, limit, firstLimit = sm.%s.Page, sm.%s.Limit, sm.%s.FirstLimit", f.Name, f.Name, f.Name)
+			g.p("}")
+			break
+		}
+	}
+	g.p("pagingQuery := query")
+	g.p("if limit > 0 {")
+	g.p("pagingQuery = %sBuildPagingQuery(query, page, limit, firstLimit, driver)", q)
+	g.p("}")
+	g.p("rows, err := %sGetQuerier(ctx, db).QueryContext(ctx, pagingQuery, params...)", q)
+	g.p("if err != nil {")
+	g.p("return nil, -1, err")
+	g.p("}")
+	g.p("models, err := Scan%s(rows, driver)", model.Name)
+	g.p("rows.Close()")
+	g.p("if err != nil {")
+	g.p("return nil, -1, err")
+	g.p("}")
+	g.p("if limit <= 0 {")
+	g.p("return &models, int64(len(models)), nil")
+	g.p("}")
+	g.p("total, err := %sBuildCountFromQuery(ctx, %sGetQuerier(ctx, db), query, params)", q, q)
+	g.p("if err != nil {")
+	g.p("return nil, -1, err")
+	g.p("}")
+	g.p("return &models, total, nil")
+	g.p("}")
+	g.p("}")
+	return nil
+}
+
+func (g *generator) generateField(sm *Struct, f Field, x string, columnName string) error {
+	q := g.q
+	param := q + "BuildParam(marker+1, driver)"
+	switch f.Kind {
+	case kindDateRange, kindTimeRange:
+		g.needTime = true
+		start, end := "StartDate", "EndDate"
+		if f.Kind == kindTimeRange {
+			start, end = "StartTime", "EndTime"
+		}
+		g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %sGreaterEqualThan, %s))`, columnName, q, param)
+		g.p("queryValues = append(queryValues, %s.%s)", x, start)
+		g.p("marker++")
+		g.p("{")
+		g.p("var eDate = %s.%s.Add(time.Hour * 24)", x, end)
+		g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %sLighterThan, %s))`, columnName, q, param)
+		g.p("queryValues = append(queryValues, &eDate)")
+		g.p("marker++")
+		g.p("}")
+	case kindNumberRange:
+		for _, c := range [][3]string{{"Min", "Lower", "GreaterEqualThan"}, {"Max", "Upper", "LighterEqualThan"}} {
+			strict := "GreaterThan"
+			if c[2] == "LighterEqualThan" {
+				strict = "LighterThan"
+			}
+			g.p("if %s.%s != nil {", x, c[0])
+			g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %s%s, %s))`, columnName, q, c[2], param)
+			g.p("queryValues = append(queryValues, %s.%s)", x, c[0])
+			g.p("marker++")
+			g.p("} else if %s.%s != nil {", x, c[1])
+			g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %s%s, %s))`, columnName, q, strict, param)
+			g.p("queryValues = append(queryValues, %s.%s)", x, c[1])
+			g.p("marker++")
+			g.p("}")
+		}
+	case kindString:
+		if f.Ptr {
+			return fmt.Errorf("%s.%s: *string is not supported by BuildQuery", sm.Name, f.Name)
+		}
+		like := fmt.Sprintf(`if driver == %sDriverPostgres {
+			rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, "ilike", param))
+		} else {
+			rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %sLike, param))
+		}`, q, columnName, columnName, q)
+		g.p("if len(%s) > 0 {", x)
+		g.p("param := %s", param)
+		key, ok := f.Tag.Lookup("match")
+		if !ok {
+			key = "contain"
+		}
+		format, exist := keywordFormat[key]
+		if !exist {
+			return fmt.Errorf("%s.%s: match not support %q format", sm.Name, f.Name, key)
+		}
+		g.p("queryValues = append(queryValues, %s)", FormatExpr(format, x))
+		g.p("%s", like)
+		g.p("marker++")
+		if key, ok := f.Tag.Lookup("keyword"); ok {
+			format, exist := keywordFormat[key]
+			if !exist || format == "?" {
+				return fmt.Errorf("%s.%s: keyword not support %q format", sm.Name, f.Name, key)
+			}
+			g.p("} else if len(keyword) > 0 {")
+			g.p("keywordColumns = append(keywordColumns, %q)", columnName)
+			g.p("keywordValues = append(keywordValues, %s)", FormatExpr(format, "keyword"))
+		}
+		g.p("}")
+	case kindSlice:
+		v := x
+		if f.Ptr {
+			v = "(*" + x + ")"
+		}
+		g.p("if len(%s) > 0 {", v)
+		g.p(`format := fmt.Sprintf("(%%s)", %sBuildParametersFrom(marker, len(%s), driver))`, q, v)
+		g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %sIn, format))`, columnName, q)
+		g.p("for _, v := range %s {", v)
+		g.p("queryValues = append(queryValues, v)")
+		g.p("}")
+		g.p("marker += len(%s)", v)
+		g.p("}")
+	default:
+		g.p(`rawConditions = append(rawConditions, fmt.Sprintf("%%s %%s %%s", %q, %sExact, %s))`, columnName, q, param)
+		g.p("queryValues = append(queryValues, %s)", x)
+		g.p("marker++")
+	}
+	return nil
+}
+
+// FormatExpr returns the expression of the format of match or keyword tag, such as "%" + sm.Name + "%"
+func FormatExpr(format string, x string) string {
+	parts := strings.Split(format, "?")
+	exprs := make([]string, 0)
+	for i, part := range parts {
+		if i > 0 {
+			exprs = append(exprs, x)
+		}
+		if len(part) > 0 {
+			exprs = append(exprs, strconv.Quote(part))
+		}
+	}
+	return strings.Join(exprs, " + ")
+}
+
+func (g *generator) generateModel(model *Struct) {
+	q := g.q
+	modelVar := lowerFirst(model.Name)
+	names, columns := model.JsonColumns()
+	g.p("")
+	g.p("var %sJsonColumns = map[string]string{", modelVar)
+	for _, name := range names {
+		g.p("%q: %q,", name, columns[name])
+	}
+	g.p("}")
+	g.p("var %sColumns = map[string]bool{", modelVar)
+	for _, column := range model.Columns() {
+		g.p("%q: true,", column)
+	}
+	g.p("}")
+	g.p("")
+	g.p("// build%sSort builds the order by clause like %sBuildSort, without reflection; the invalid fields are skipped, check%sSort returns them as error", model.Name, q, model.Name)
+	g.p("func build%sSort(sortString string) string {", model.Name)
+	g.p("var sort = make([]string, 0)")
+	g.p(`for _, s := range strings.Split(sortString, ",") {`)
+	g.p("sortField := strings.TrimSpace(s)")
+	g.p("if len(sortField) == 0 {")
+	g.p("continue")
+	g.p("}")
+	g.p("fieldName := sortField")
+	g.p("c := sortField[0:1]")
+	g.p(`if c == "-" || c == "+" {`)
+	g.p("fieldName = strings.TrimSpace(sortField[1:])")
+	g.p("}")
+	g.p("columnName, ok := %sJsonColumns[fieldName]", modelVar)
+	g.p("if !ok {")
+	g.p("if !%sColumns[fieldName] {", modelVar)
+	g.p("continue")
+	g.p("}")
+	g.p("columnName = fieldName")
+	g.p("}")
+	g.p(`sort = append(sort, columnName+" "+%sGetSortType(c))`, q)
+	g.p("}")
+	g.p("if len(sort) == 0 {")
+	g.p(`return ""`)
+	g.p("}")
+	g.p("return ` order by ` + strings.Join(sort, \",\")")
+	g.p("}")
+	g.p("")
+	g.p("// check%sSort returns the invalid sort field as error like %sCheckSort, without reflection", model.Name, q)
+	g.p("func check%sSort(sortString string) error {", model.Name)
+	g.p(`for _, s := range strings.Split(sortString, ",") {`)
+	g.p("sortField := strings.TrimSpace(s)")
+	g.p("if len(sortField) == 0 {")
+	g.p("continue")
+	g.p("}")
+	g.p("fieldName := sortField")
+	g.p(`if c := sortField[0:1]; c == "-" || c == "+" {`)
+	g.p("fieldName = strings.TrimSpace(sortField[1:])")
+	g.p("}")
+	g.p("if _, ok := %sJsonColumns[fieldName]; !ok && !%sColumns[fieldName] {", modelVar, modelVar)
+	g.p("return %sNewInvalidSortError(fieldName)", q)
+	g.p("}")
+	g.p("}")
+	g.p("return nil")
+	g.p("}")
+
+	// the last field of the column wins, like search.GetColumnIndexes
+	fields := make(map[string]int)
+	order := make([]string, 0)
+	fieldNames := make([]string, 0)
+	for _, f := range model.Fields {
+		if f.Embedded || !ast.IsExported(f.Name) {
+			continue
+		}
+		if column, ok := search.FindTag(f.Tag.Get("gorm"), "column"); ok {
+			if _, exist := fields[column]; !exist {
+				order = append(order, column)
+			}
+			fields[column] = len(fieldNames)
+			fieldNames = append(fieldNames, f.Name)
+		}
+	}
+	g.p("")
+	g.p("// %sFields and %sOracleFields are the fields of the columns, like %sGetColumnIndexes", modelVar, modelVar, q)
+	g.p("var %sFields = map[string]int{", modelVar)
+	for _, column := range order {
+		g.p("%q: %d,", column, fields[column])
+	}
+	g.p("}")
+	g.p("var %sOracleFields = map[string]int{", modelVar)
+	for _, column := range order {
+		g.p("%q: %d,", strings.ToUpper(column), fields[column])
+	}
+	g.p("}")
+	g.p("")
+	g.p("// Scan%s scans the rows to %s by the column names, upper case for oracle, like %sQueryWithContext; the other columns are skipped", model.Name, model.Name, q)
+	g.p("func Scan%s(rows *sql.Rows, driver string) ([]%s, error) {", model.Name, model.Name)
+	g.p("columns, err := rows.Columns()")
+	g.p("if err != nil {")
+	g.p("return nil, err")
+	g.p("}")
+	g.p("fields := %sFields", modelVar)
+	g.p("if driver == %sDriverOracle {", q)
+	g.p("fields = %sOracleFields", modelVar)
+	g.p("}")
+	g.p("indexes := make([]int, len(columns))")
+	g.p("for i, column := range columns {")
+	g.p("if j, ok := fields[column]; ok {")
+	g.p("indexes[i] = j")
+	g.p("} else {")
+	g.p("indexes[i] = -1")
+	g.p("}")
+	g.p("}")
+	g.p("models := make([]%s, 0)", model.Name)
+	g.p("dest := make([]interface{}, len(columns))")
+	g.p("for rows.Next() {")
+	g.p("var m %s", model.Name)
+	g.p("for i, j := range indexes {")
+	g.p("switch j {")
+	for _, column := range order {
+		g.p("case %d:", fields[column])
+		g.p("dest[i] = &m.%s", fieldNames[fields[column]])
+	}
+	g.p("default:")
+	g.p("dest[i] = new(interface{})")
+	g.p("}")
+	g.p("}")
+	g.p("if err := rows.Scan(dest...); err != nil {")
+	g.p("return models, err")
+	g.p("}")
+	g.p("models = append(models, m)")
+	g.p("}")
+	g.p("return models, rows.Err()")
+	g.p("}")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the generated code of testdata/example")
+
+const exampleDir = "testdata/example"
+
+// TestGenerate compares the generated code of testdata/example with search_gen.go, which is checked by the tests of the example
+func TestGenerate(t *testing.T) {
+	pkg, structs, err := ParseDir(exampleDir, "search_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := GetPairs(structs, "")
+	if err != nil || len(pairs) != 1 || pairs[0] != [2]string{"UserSM", "User"} {
+		t.Fatalf("pairs = %v %v", pairs, err)
+	}
+	src, err := Generate(pkg, structs, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join(exampleDir, "search_gen.go")
+	if *update {
+		if err := os.WriteFile(golden, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("the generated code differs from %s; run go test -update", golden)
+	}
+}
+
+// TestGeneratedCode compiles the example and runs its tests, which compare the generated code with the runtime query builder and scanner
+func TestGeneratedCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("it runs go test")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not found")
+	}
+	out, err := exec.Command(goTool, "test", "./"+exampleDir).CombinedOutput()
+	if err != nil {
+		t.Errorf("%v\n%s", err, out)
+	}
+}
+
+func TestGetPairs(t *testing.T) {
+	_, structs, err := ParseDir(exampleDir, "search_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pairs, err := GetPairs(structs, "UserSM=User"); err != nil || len(pairs) != 1 {
+		t.Errorf("pairs = %v %v", pairs, err)
+	}
+	for _, types := range []string{"User=UserSM", "UserSM=Role", "UserSM"} {
+		if _, err := GetPairs(structs, types); err == nil {
+			t.Errorf("%s must fail", types)
+		}
+	}
+}
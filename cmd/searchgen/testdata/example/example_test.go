@@ -0,0 +1,142 @@
+package example
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/common-go/search"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+// TestBuildQuery compares the generated query builder with search.BuildQuery
+func TestBuildQuery(t *testing.T) {
+	sms := []*UserSM{
+		{SearchModel: &search.SearchModel{Keyword: " jo "}, Status: []string{"A", "I"}, Salary: &search.NumberRange{Min: float(1000), Upper: float(5000)}},
+		{SearchModel: &search.SearchModel{Keyword: "jo", Fields: []string{"id", "email"}, Sort: "-salary,username"}, Username: "jo"},
+		{SearchModel: &search.SearchModel{Excluding: map[string][]interface{}{"status": {"D"}}}, Email: "test.com"},
+		{SearchModel: &search.SearchModel{}},
+	}
+	modelType := reflect.TypeOf(User{})
+	for _, driver := range []string{search.DriverPostgres, search.DriverOracle, search.DriverMysql} {
+		runtime := search.NewDefaultQueryBuilder("users", modelType, driver)
+		for i, sm := range sms {
+			want, wantParams := runtime.BuildQuery(sm)
+			got, params := BuildUserSMQuery(sm, "users", driver)
+			if got != want || !reflect.DeepEqual(params, wantParams) {
+				t.Errorf("%s %d:\n got %q %v\nwant %q %v", driver, i, got, params, want, wantParams)
+			}
+		}
+	}
+}
+
+// TestCheckUserSM compares the generated check with search.CheckSearchModel
+func TestCheckUserSM(t *testing.T) {
+	sms := []*UserSM{
+		{SearchModel: &search.SearchModel{Sort: "-salary,username"}},
+		{SearchModel: &search.SearchModel{Sort: "-password"}},
+		{SearchModel: &search.SearchModel{Excluding: map[string][]interface{}{"status": {"D"}}}},
+		{SearchModel: &search.SearchModel{Excluding: map[string][]interface{}{"password": {"x"}}}},
+		{},
+	}
+	modelType := reflect.TypeOf(User{})
+	for i, sm := range sms {
+		want := search.CheckSearchModel(sm, modelType)
+		if err := CheckUserSM(sm); !reflect.DeepEqual(err, want) {
+			t.Errorf("%d: got %v, want %v", i, err, want)
+		}
+	}
+}
+
+// TestScanUser checks that the generated scanner matches the columns like the runtime scanner, upper case for oracle
+func TestScanUser(t *testing.T) {
+	db, err := sql.Open("example", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cases := []struct {
+		driver  string
+		columns []string
+		want    User
+	}{
+		{search.DriverPostgres, []string{"id", "username", "other"}, User{Id: "1", Username: "john"}},
+		{search.DriverPostgres, []string{"ID", "USERNAME", "other"}, User{}},
+		{search.DriverOracle, []string{"ID", "USERNAME", "OTHER"}, User{Id: "1", Username: "john"}},
+	}
+	for _, c := range cases {
+		rows, err := db.QueryContext(context.WithValue(context.Background(), columnsKey{}, c.columns), "select")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users, err := ScanUser(rows, c.driver)
+		rows.Close()
+		if err != nil || len(users) != 1 || users[0] != c.want {
+			t.Errorf("%s %v: %+v %v", c.driver, c.columns, users, err)
+		}
+	}
+}
+
+// TestSearchErrors checks that the generated search returns the invalid sort and the error of the count
+func TestSearchErrors(t *testing.T) {
+	db, err := sql.Open("example", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), columnsKey{}, []string{"id", "username"})
+	s := NewUserSMSearch(db, "users")
+	if _, _, err := s(ctx, &UserSM{SearchModel: &search.SearchModel{Sort: "password"}}); err == nil {
+		t.Error("the invalid sort must be returned")
+	}
+	if users, total, err := s(ctx, &UserSM{SearchModel: &search.SearchModel{Limit: 10}}); err == nil || users != nil {
+		t.Errorf("the error of the count must be returned: %v %d %v", users, total, err)
+	}
+	if users, total, err := s(ctx, &UserSM{SearchModel: &search.SearchModel{}}); err != nil || total != 1 {
+		t.Errorf("%v %d %v", users, total, err)
+	}
+}
+
+func init() {
+	sql.Register("example", exampleDriver{})
+}
+
+type columnsKey struct{}
+type exampleDriver struct{}
+type exampleConn struct{}
+type exampleRows struct {
+	columns []string
+	done    bool
+}
+
+func (exampleDriver) Open(name string) (driver.Conn, error) { return exampleConn{}, nil }
+func (exampleConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+func (exampleConn) Close() error              { return nil }
+func (exampleConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+func (exampleConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.HasPrefix(query, "select count(") {
+		return nil, errors.New("the count failed")
+	}
+	return &exampleRows{columns: ctx.Value(columnsKey{}).([]string)}, nil
+}
+func (r *exampleRows) Columns() []string { return r.columns }
+func (r *exampleRows) Close() error      { return nil }
+func (r *exampleRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	values := []driver.Value{"1", "john", "x"}
+	copy(dest, values)
+	return nil
+}
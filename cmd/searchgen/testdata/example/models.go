@@ -0,0 +1,18 @@
+package example
+
+import "github.com/common-go/search"
+
+type User struct {
+	Id       string  `json:"id" gorm:"column:id;primary_key"`
+	Username string  `json:"username" gorm:"column:username"`
+	Email    string  `json:"email" gorm:"column:email"`
+	Status   string  `json:"status" gorm:"column:status"`
+	Salary   float64 `json:"salary" gorm:"column:salary"`
+}
+type UserSM struct {
+	*search.SearchModel
+	Username string              `json:"username" gorm:"column:username" keyword:"prefix" match:"prefix"`
+	Email    string              `json:"email" gorm:"column:email" keyword:"contain"`
+	Status   []string            `json:"status" gorm:"column:status"`
+	Salary   *search.NumberRange `json:"salary" gorm:"column:salary"`
+}
@@ -0,0 +1,228 @@
+package search
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CountExact    = "exact"
+	CountCached   = "cached"
+	CountCapped   = "capped"
+	CountEstimate = "estimate"
+	CountCap      = 10000
+	CountCacheMax = 10000
+)
+
+// CountConfig selects how SearchBuilder counts the total
+type CountConfig struct {
+	// CountExact, CountCached, CountCapped or CountEstimate
+	Strategy string
+	// the time to live of the cached totals, for CountCached
+	TTL time.Duration
+	// counts up to Cap rows, for CountCapped; the total is Cap and not exact if there are more rows
+	Cap int64
+	// the max number of the cached totals, for CountCached; the least recently used one is evicted
+	MaxSize int
+	mu      sync.Mutex
+	totals  map[string]*list.Element
+	lru     *list.List
+}
+type cachedTotal struct {
+	key    string
+	total  int64
+	expiry time.Time
+}
+
+func NewCountConfig(strategy string) *CountConfig {
+	return &CountConfig{Strategy: strategy, TTL: time.Minute, Cap: CountCap, MaxSize: CountCacheMax, totals: make(map[string]*list.Element), lru: list.New()}
+}
+func NewCachedCount(ttl time.Duration) *CountConfig {
+	c := NewCountConfig(CountCached)
+	c.TTL = ttl
+	return c
+}
+func NewCappedCount(cap int64) *CountConfig {
+	c := NewCountConfig(CountCapped)
+	c.Cap = cap
+	return c
+}
+
+// TotalInfo is set by SearchBuilder, if it is in the context, to tell SearchHandler whether the total is exact or unknown
+type TotalInfo struct {
+	Strategy string
+	Exact    bool
+	// the count did not finish in time; HasNext is decided by the page query
+	Unknown bool
+	HasNext bool
+}
+type totalInfoKey struct{}
+
+func WithTotalInfo(ctx context.Context, info *TotalInfo) context.Context {
+	return context.WithValue(ctx, totalInfoKey{}, info)
+}
+func GetTotalInfo(ctx context.Context) *TotalInfo {
+	if info, ok := ctx.Value(totalInfoKey{}).(*TotalInfo); ok {
+		return info
+	}
+	return nil
+}
+
+// Count counts the rows of the query by the strategy, and returns whether the total is exact;
+// the cached total, which may be stale, and the total of an error are not exact
+func (c *CountConfig) Count(ctx context.Context, db Querier, query string, params []interface{}) (int64, bool, error) {
+	driver := GetDriver(db)
+	switch c.Strategy {
+	case CountCached:
+		queryCount, paramsCount := BuildCountQuery(query, params)
+		key, err := countKey(queryCount, paramsCount)
+		if err != nil {
+			total, err := BuildCountFromQuery(ctx, db, query, params)
+			return total, err == nil, err
+		}
+		if total, ok := c.load(key); ok {
+			// the cached total may be stale
+			return total, false, nil
+		}
+		total, err := BuildCountFromQuery(ctx, db, query, params)
+		if err != nil {
+			return total, false, err
+		}
+		c.store(key, total)
+		return total, true, nil
+	case CountCapped:
+		queryCount := BuildCappedCountQuery(query, c.Cap, driver)
+		ctx2, end := StartSpan(ctx, StageCount)
+		total, err := CountWithContext(ctx2, db, queryCount, params...)
+		end(total, err)
+		if err != nil {
+			return total, false, err
+		}
+		if total > c.Cap {
+			return c.Cap, false, nil
+		}
+		return total, true, nil
+	case CountEstimate:
+		if driver == DriverPostgres && !HasFilters(query, params) {
+			ctx2, end := StartSpan(ctx, StageCount)
+			total, err := EstimateCount(ctx2, db, query)
+			end(total, err)
+			if err == nil {
+				return total, false, nil
+			}
+		}
+	}
+	total, err := BuildCountFromQuery(ctx, db, query, params)
+	return total, err == nil, err
+}
+func (c *CountConfig) load(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.totals[key]
+	if !ok {
+		return 0, false
+	}
+	t := e.Value.(*cachedTotal)
+	if !time.Now().Before(t.expiry) {
+		c.lru.Remove(e)
+		delete(c.totals, key)
+		return 0, false
+	}
+	c.lru.MoveToFront(e)
+	return t.total, true
+}
+func (c *CountConfig) store(key string, total int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.totals == nil {
+		c.totals = make(map[string]*list.Element)
+		c.lru = list.New()
+	}
+	if e, ok := c.totals[key]; ok {
+		c.lru.Remove(e)
+	}
+	c.totals[key] = c.lru.PushFront(&cachedTotal{key: key, total: total, expiry: time.Now().Add(c.TTL)})
+	max := c.MaxSize
+	if max <= 0 {
+		max = CountCacheMax
+	}
+	for c.lru.Len() > max {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.totals, e.Value.(*cachedTotal).key)
+	}
+}
+
+// Len returns the number of the cached totals
+func (c *CountConfig) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.totals)
+}
+func countKey(query string, params []interface{}) (string, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(query + "\x00" + string(b)))
+	return hex.EncodeToString(h[:]), nil
+}
+
+// HasFilters returns true if the query has where clause or parameters
+func HasFilters(query string, params []interface{}) bool {
+	return len(params) > 0 || strings.Contains(strings.ToLower(query), " where ")
+}
+
+// BuildCappedCountQuery counts up to limit+1 rows of the query
+func BuildCappedCountQuery(query string, limit int64, driver string) string {
+	j := strings.Index(query, " from ")
+	if j < 0 {
+		return query
+	}
+	k := strings.Index(query, " order by ")
+	if k < 0 || k < j {
+		k = len(query)
+	}
+	from := query[j:k]
+	n := strconv.FormatInt(limit+1, 10)
+	if strings.Contains(query, " distinct ") {
+		i := strings.Index(query, "select ")
+		from = " from (" + query[i:k] + ") as main"
+	}
+	switch driver {
+	case DriverMssql:
+		return "select count(*) as total from (select top " + n + " 1 as x" + from + ") as capped"
+	case DriverOracle:
+		return "select count(*) as total from (select 1 as x" + from + " fetch first " + n + " rows only) capped"
+	default:
+		return "select count(*) as total from (select 1 as x" + from + " limit " + n + ") as capped"
+	}
+}
+
+// EstimateCount returns the planner estimate of the rows of the query by EXPLAIN, for postgres
+func EstimateCount(ctx context.Context, db Querier, query string) (int64, error) {
+	var plan string
+	if err := GetQuerier(ctx, db).QueryRowContext(ctx, "explain (format json) "+query).Scan(&plan); err != nil {
+		return 0, err
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &plans); err != nil {
+		return 0, err
+	}
+	if len(plans) == 0 {
+		return 0, fmt.Errorf("no plan of %s", query)
+	}
+	return int64(plans[0].Plan.Rows), nil
+}
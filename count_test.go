@@ -0,0 +1,82 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountCacheIsBounded(t *testing.T) {
+	c := NewCachedCount(time.Minute)
+	c.MaxSize = 2
+	c.store("a", 1)
+	c.store("b", 2)
+	if _, ok := c.load("a"); !ok {
+		t.Fatal("a must be cached")
+	}
+	c.store("c", 3)
+	if c.Len() != 2 {
+		t.Errorf("len = %d, want 2", c.Len())
+	}
+	if _, ok := c.load("b"); ok {
+		t.Error("the least recently used total must be evicted")
+	}
+	if total, ok := c.load("a"); !ok || total != 1 {
+		t.Errorf("a = %d %v", total, ok)
+	}
+
+	expired := NewCachedCount(-time.Second)
+	expired.store("a", 1)
+	if _, ok := expired.load("a"); ok || expired.Len() != 0 {
+		t.Error("the expired total must be removed")
+	}
+}
+
+func TestCachedCount(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	builder.Counting = NewCachedCount(time.Minute)
+	sm := &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}, Status: []string{"A"}}
+	for i := 0; i < 2; i++ {
+		info := &TotalInfo{}
+		_, total, err := builder.Search(WithTotalInfo(context.Background(), info), sm)
+		if err != nil || total != 25 || info.Exact != (i == 0) || info.Strategy != CountCached {
+			t.Fatalf("the counted total is exact, the cached one is not: %d total %d, err %v, info %+v", i, total, err, info)
+		}
+	}
+	count := 0
+	for _, q := range f.Queries() {
+		if isCountQuery(q.Query) {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("count queries = %d, want 1", count)
+	}
+}
+
+func TestCappedCount(t *testing.T) {
+	builder, f := newUserDB(t, 11)
+	builder.Counting = NewCappedCount(10)
+	info := &TotalInfo{}
+	_, total, err := builder.Search(WithTotalInfo(context.Background(), info), &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}})
+	if err != nil || total != 10 || info.Exact {
+		t.Fatalf("total %d, err %v, info %+v", total, err, info)
+	}
+	queries := f.Queries()
+	if q := queries[len(queries)-1].Query; !strings.HasSuffix(q, " limit 11) as capped") {
+		t.Errorf("query = %q", q)
+	}
+}
+
+func TestCountErrorIsNotExact(t *testing.T) {
+	db, _ := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		return nil, errors.New("connection reset")
+	})
+	for _, strategy := range []string{CountExact, CountCached, CountCapped, CountEstimate} {
+		if _, exact, err := NewCountConfig(strategy).Count(context.Background(), db, "select * from users", nil); err == nil || exact {
+			t.Errorf("%s: exact %v, err %v", strategy, exact, err)
+		}
+	}
+}
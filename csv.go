@@ -79,13 +79,13 @@ func findIndexByTagJsonOrEmbededFieldName(model interface{}, jsonNames []string,
 	firstLayerIndex = map[string]int{}
 	secondLayerIndexes = map[string]int{}
 	modelValue := reflect.Indirect(reflect.ValueOf(model))
-	numField := modelValue.NumField()
+	metadata := GetMetadata(modelValue.Type())
 
-	for i := 0; i < numField; i++ {
-		if jsonTag, exist := modelValue.Type().Field(i).Tag.Lookup("json"); exist {
+	for i := range metadata.Fields {
+		field := &metadata.Fields[i]
+		if field.HasJson {
 			for j, name := range tmp {
-				tags := strings.Split(jsonTag, ",")
-				for _, tag := range tags {
+				for _, tag := range field.JsonTags {
 					if strings.Compare(strings.TrimSpace(tag), name) == 0 {
 						firstLayerIndex[name] = i
 						tmp = append(tmp[:j], tmp[j+1:]...)
@@ -94,7 +94,7 @@ func findIndexByTagJsonOrEmbededFieldName(model interface{}, jsonNames []string,
 				}
 			}
 		}
-		if modelValue.Type().Field(i).Name == embedFieldName {
+		if field.Name == embedFieldName {
 			firstLayerIndex[embedFieldName] = i
 			for j, name := range tmp {
 				embedValue := reflect.Indirect(modelValue.Field(i))
@@ -110,16 +110,8 @@ func findIndexByTagJsonOrEmbededFieldName(model interface{}, jsonNames []string,
 }
 
 func findIndexByTagJson(modelType reflect.Type, jsonName string) (int, string) {
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		field := modelType.Field(i)
-		jsonTag := field.Tag.Get("json")
-		tags := strings.Split(jsonTag, ",")
-		for _, tag := range tags {
-			if strings.Compare(strings.TrimSpace(tag), jsonName) == 0 {
-				return i, field.Name
-			}
-		}
+	if f, ok := GetMetadata(modelType).FieldByJsonTag(jsonName); ok {
+		return f.Index, f.Name
 	}
 	return -1, ""
 }
@@ -32,7 +32,8 @@ func NewExtractor(options...string) *Extractor {
 }
 
 func (e *Extractor) Extract(m interface{}) (int64, int64, int64, error) {
-	if sModel, ok0 := m.(*SearchModel); ok0 {
+	if g, ok0 := m.(searchModelGetter); ok0 && g.GetSearchModel() != nil {
+		sModel := g.GetSearchModel()
 		return sModel.Page, sModel.Limit, sModel.FirstLimit, nil
 	}
 	var page, limit, firstLimit int64
@@ -70,15 +71,13 @@ func (e *Extractor) Extract(m interface{}) (int64, int64, int64, error) {
 }
 
 func ExtractSearch(m interface{}) (int64, int64, int64, error) {
-	if sModel, ok := m.(*SearchModel); ok {
+	if g, ok := m.(searchModelGetter); ok && g.GetSearchModel() != nil {
+		sModel := g.GetSearchModel()
 		return sModel.Page, sModel.Limit, sModel.FirstLimit, nil
 	} else {
 		value := reflect.Indirect(reflect.ValueOf(m))
-		numField := value.NumField()
-		for i := 0; i < numField; i++ {
-			if sModel1, ok := value.Field(i).Interface().(*SearchModel); ok {
-				return sModel1.Page, sModel1.Limit, sModel1.FirstLimit, nil
-			}
+		if sModel1 := searchModelOf(value); sModel1 != nil {
+			return sModel1.Page, sModel1.Limit, sModel1.FirstLimit, nil
 		}
 		return 0, 0, 0, errors.New("cannot extract sort, pageIndex, pageSize, firstPageSize from model")
 	}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+func (b *SearchBuilder) Facets(ctx context.Context, m interface{}) (map[string]map[string]int64, error) {
+	sm := GetSearchModel(m)
+	if sm == nil || len(sm.Facets) == 0 {
+		return nil, nil
+	}
+	return BuildFacets(ctx, b.db(), m, sm.Facets, b.ModelType, b.Build)
+}
+
+// BuildFacets counts rows per value of each facet, over the filtered query without the filter of the facet itself
+func BuildFacets(ctx context.Context, db Querier, m interface{}, facets []string, modelType reflect.Type, buildQuery func(ctx context.Context, sm interface{}) (string, []interface{}, error)) (map[string]map[string]int64, error) {
+	result := make(map[string]map[string]int64)
+	for _, facet := range facets {
+		i, _, column := GetFieldByJson(modelType, facet)
+		if i < 0 || len(column) == 0 {
+			return nil, NewUnknownFieldError(facet)
+		}
+		query, params, err := buildQuery(ctx, ExcludeField(m, facet))
+		if err != nil {
+			return nil, err
+		}
+		facetQuery := BuildFacetQuery(query, column)
+		counts, err := QueryFacet(ctx, db, facetQuery, params...)
+		if err != nil {
+			return nil, err
+		}
+		result[facet] = counts
+	}
+	return result, nil
+}
+
+// ExcludeField returns a shallow copy of the search model, with the field tagged by json name reset to zero value
+func ExcludeField(m interface{}, jsonName string) interface{} {
+	value := reflect.Indirect(reflect.ValueOf(m))
+	if value.Kind() != reflect.Struct {
+		return m
+	}
+	i, _ := findIndexByTagJson(value.Type(), jsonName)
+	if i < 0 {
+		return m
+	}
+	c := reflect.New(value.Type())
+	c.Elem().Set(value)
+	field := c.Elem().Field(i)
+	field.Set(reflect.Zero(field.Type()))
+	return c.Interface()
+}
+
+func BuildFacetQuery(sql string, column string) string {
+	i := strings.Index(sql, "select ")
+	if i < 0 {
+		return sql
+	}
+	j := strings.Index(sql, " from ")
+	if j < 0 {
+		return sql
+	}
+	k := strings.Index(sql, " order by ")
+	if k < 0 {
+		k = len(sql)
+	}
+	h := strings.Index(sql, " distinct ")
+	if h > 0 {
+		return fmt.Sprintf("select main.%s as value, count(*) as total from (%s) as main group by main.%s", column, sql[i:k], column)
+	}
+	return fmt.Sprintf("select %s as value, count(*) as total %s group by %s", column, sql[j:k], column)
+}
+
+func QueryFacet(ctx context.Context, db Querier, sql string, values ...interface{}) (map[string]int64, error) {
+	rows, er1 := GetQuerier(ctx, db).QueryContext(ctx, sql, values...)
+	if er1 != nil {
+		return nil, er1
+	}
+	defer rows.Close()
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var v interface{}
+		var c int64
+		if er2 := rows.Scan(&v, &c); er2 != nil {
+			return nil, er2
+		}
+		counts[ToKey(v)] = c
+	}
+	if er3 := rows.Err(); er3 != nil {
+		return nil, er3
+	}
+	return counts, nil
+}
+
+func ToKey(v interface{}) string {
+	switch k := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(k)
+	case string:
+		return k
+	default:
+		return fmt.Sprintf("%v", k)
+	}
+}
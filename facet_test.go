@@ -0,0 +1,52 @@
+package search
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestBuildFacetQuery(t *testing.T) {
+	cases := map[string]string{
+		"select id,status from users where username like ? order by id": "select status as value, count(*) as total  from users where username like ? group by status",
+		"select distinct status from users where username like ?":       "select main.status as value, count(*) as total from (select distinct status from users where username like ?) as main group by main.status",
+		"select id from users":          "select status as value, count(*) as total  from users group by status",
+		"update users set status = 'A'": "update users set status = 'A'",
+	}
+	for query, want := range cases {
+		if got := BuildFacetQuery(query, "status"); got != want {
+			t.Errorf("BuildFacetQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestFacetsExcludeOwnFilter(t *testing.T) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		return rowsOf([]string{"value", "total"}, []driver.Value{"A", int64(3)}, []driver.Value{[]byte("I"), int64(2)}, []driver.Value{nil, int64(1)}), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	sm := &testUserSM{SearchModel: &SearchModel{Facets: []string{"status"}}, Username: "jo", Status: []string{"A"}}
+	facets, err := builder.Facets(context.Background(), sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"A": 3, "I": 2, "": 1}
+	if !reflect.DeepEqual(facets["status"], want) {
+		t.Errorf("facets = %v, want %v", facets, want)
+	}
+	queries := f.Queries()
+	if len(queries) != 1 {
+		t.Fatalf("queries = %+v", queries)
+	}
+	if q := queries[0]; q.Query != "select status as value, count(*) as total  from users where username like ? group by status" || !reflect.DeepEqual(q.Args, []interface{}{"%jo%"}) {
+		t.Errorf("facet query = %q %v", q.Query, q.Args)
+	}
+	if len(sm.Status) != 1 {
+		t.Errorf("the search model must not be changed: %+v", sm)
+	}
+	if _, err := builder.Facets(context.Background(), &testUserSM{SearchModel: &SearchModel{Facets: []string{"unknown"}}}); err == nil {
+		t.Error("unknown facet must fail")
+	}
+}
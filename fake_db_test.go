@@ -0,0 +1,223 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is the database of fakeDriver, which records the queries and answers them by respond
+type fakeDB struct {
+	mu        sync.Mutex
+	queries   []fakeQuery
+	respond   func(ctx context.Context, query string, args []interface{}) (*fakeRows, error)
+	begins    []driver.TxOptions
+	rollbacks int
+	prepares  int
+	closes    int
+}
+type fakeQuery struct {
+	Query string
+	Args  []interface{}
+	InTx  bool
+}
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	i       int
+}
+
+var (
+	fakeMu  sync.Mutex
+	fakeDBs = make(map[string]*fakeDB)
+	fakeSeq int
+)
+
+func init() {
+	sql.Register("fake", fakeDriver{})
+}
+
+// newFakeDB opens *sql.DB of a new fakeDB; if respond is nil, the count queries return 0 and the others return no row
+func newFakeDB(t testing.TB, respond func(ctx context.Context, query string, args []interface{}) (*fakeRows, error)) (*sql.DB, *fakeDB) {
+	f := &fakeDB{respond: respond}
+	fakeMu.Lock()
+	fakeSeq++
+	name := "fake" + strconv.Itoa(fakeSeq)
+	fakeDBs[name] = f
+	fakeMu.Unlock()
+	db, err := sql.Open("fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+func rowsOf(columns []string, values ...[]driver.Value) *fakeRows {
+	return &fakeRows{columns: columns, values: values}
+}
+func totalOf(total int64) *fakeRows {
+	return rowsOf([]string{"total"}, []driver.Value{total})
+}
+func isCountQuery(query string) bool {
+	return strings.HasPrefix(query, "select count(*)")
+}
+
+// Queries returns the recorded queries
+func (f *fakeDB) Queries() []fakeQuery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeQuery(nil), f.queries...)
+}
+func (f *fakeDB) query(ctx context.Context, query string, args []driver.NamedValue, inTx bool) (driver.Rows, error) {
+	values := make([]interface{}, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	f.mu.Lock()
+	f.queries = append(f.queries, fakeQuery{Query: query, Args: values, InTx: inTx})
+	respond := f.respond
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if respond == nil {
+		if isCountQuery(query) {
+			return totalOf(0), nil
+		}
+		return rowsOf(nil), nil
+	}
+	rows, err := respond(ctx, query, values)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		return rowsOf(nil), nil
+	}
+	return &fakeRows{columns: rows.columns, values: rows.values}, nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMu.Lock()
+	f, ok := fakeDBs[name]
+	fakeMu.Unlock()
+	if !ok {
+		return nil, errors.New("unknown fake database " + name)
+	}
+	return &fakeConn{db: f}, nil
+}
+
+type fakeConn struct {
+	db   *fakeDB
+	inTx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.mu.Lock()
+	c.db.prepares++
+	c.db.mu.Unlock()
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error {
+	return nil
+}
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.db.begins = append(c.db.begins, opts)
+	c.db.mu.Unlock()
+	c.inTx = true
+	return &fakeTx{conn: c}, nil
+}
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(ctx, query, args, c.inTx)
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (t *fakeTx) Commit() error {
+	t.conn.inTx = false
+	return nil
+}
+func (t *fakeTx) Rollback() error {
+	t.conn.db.mu.Lock()
+	t.conn.db.rollbacks++
+	t.conn.db.mu.Unlock()
+	t.conn.inTx = false
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.conn.db.mu.Lock()
+	s.conn.db.closes++
+	s.conn.db.mu.Unlock()
+	return nil
+}
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("fake database is read only")
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return s.QueryContext(context.Background(), named)
+}
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.db.query(ctx, s.query, args, s.conn.inTx)
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+func (r *fakeRows) Close() error {
+	return nil
+}
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.i])
+	r.i++
+	return nil
+}
+
+// testUser and testUserSM are the model and the search model of the tests
+type testUser struct {
+	Id       string  `json:"id" gorm:"column:id;primary_key"`
+	Username string  `json:"username" gorm:"column:username"`
+	Email    string  `json:"email" gorm:"column:email"`
+	Status   string  `json:"status" gorm:"column:status"`
+	Salary   float64 `json:"salary" gorm:"column:salary" aggregate:"sum"`
+}
+type testUserSM struct {
+	*SearchModel
+	Username string   `json:"username" gorm:"column:username" keyword:"prefix"`
+	Email    string   `json:"email" gorm:"column:email" keyword:"contain"`
+	Status   []string `json:"status" gorm:"column:status"`
+}
+
+var testUserColumns = []string{"id", "username", "email", "status", "salary"}
+
+func testUserRow(id string, username string) []driver.Value {
+	return []driver.Value{id, username, username + "@test.com", "A", 1000.0}
+}
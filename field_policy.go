@@ -0,0 +1,372 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const Roles = "roles"
+
+// FieldRule restricts the field to the roles; if Mask is empty, the field is omitted, else string field is replaced by Mask
+type FieldRule struct {
+	Roles []string
+	Mask  string
+}
+
+// FieldPolicy reads the field rules from the tags, such as `roles:"hr,admin" mask:"***"`, or from Register.
+// The hidden field is omitted from the json results, so it must have omitempty json tag, unless it is the string field with Mask.
+type FieldPolicy struct {
+	Roles string
+	mu    sync.RWMutex
+	rules map[reflect.Type]map[string]FieldRule
+	// the rules of the tags and Register by type, cleared by Register
+	cache map[reflect.Type]*fieldRules
+}
+type fieldRules struct {
+	rules map[string]FieldRule
+	err   error
+}
+
+func NewFieldPolicy(options ...string) *FieldPolicy {
+	roles := Roles
+	if len(options) >= 1 && len(options[0]) > 0 {
+		roles = options[0]
+	}
+	return &FieldPolicy{Roles: roles, rules: make(map[reflect.Type]map[string]FieldRule), cache: make(map[reflect.Type]*fieldRules)}
+}
+func (p *FieldPolicy) Register(modelType reflect.Type, jsonName string, rule FieldRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.rules[modelType]
+	if !ok {
+		m = make(map[string]FieldRule)
+		p.rules[modelType] = m
+	}
+	m[jsonName] = rule
+	delete(p.cache, modelType)
+}
+
+// GetRules returns the copy of the rules of the tags and Register
+func (p *FieldPolicy) GetRules(modelType reflect.Type) map[string]FieldRule {
+	cached, _ := p.getRules(modelType)
+	rules := make(map[string]FieldRule, len(cached))
+	for k, v := range cached {
+		rules[k] = v
+	}
+	return rules
+}
+
+// getRules returns the cached rules, which must not be modified, and the error of the hidden fields, which json cannot omit
+func (p *FieldPolicy) getRules(modelType reflect.Type) (map[string]FieldRule, error) {
+	p.mu.RLock()
+	c, ok := p.cache[modelType]
+	p.mu.RUnlock()
+	if ok {
+		return c.rules, c.err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.cache[modelType]; ok {
+		return c.rules, c.err
+	}
+	rules := make(map[string]FieldRule)
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		tag, ok := field.Tag.Lookup("roles")
+		if !ok {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(name) == 0 {
+			name = field.Name
+		}
+		rules[name] = FieldRule{Roles: splitTrim(tag), Mask: field.Tag.Get("mask")}
+	}
+	for k, v := range p.rules[modelType] {
+		rules[k] = v
+	}
+	c = &fieldRules{rules: rules, err: CheckOmitted(modelType, rules)}
+	p.cache[modelType] = c
+	return c.rules, c.err
+}
+
+// CheckOmitted returns the error of the field of the rules, which json does not omit when it is hidden
+func CheckOmitted(modelType reflect.Type, rules map[string]FieldRule) error {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		i, _ := findIndexByTagJson(modelType, name)
+		if i < 0 {
+			continue
+		}
+		field := modelType.Field(i)
+		t := field.Type
+		if len(rules[name].Mask) > 0 && (t.Kind() == reflect.String || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.String)) {
+			continue
+		}
+		omitEmpty := false
+		for _, option := range strings.Split(field.Tag.Get("json"), ",")[1:] {
+			omitEmpty = omitEmpty || option == "omitempty"
+		}
+		if !omitEmpty || t.Kind() == reflect.Struct || t.Kind() == reflect.Array {
+			return fmt.Errorf("field %s of %s is not omitted when it is hidden: it must have omitempty json tag, and not be struct or array", name, modelType.Name())
+		}
+	}
+	return nil
+}
+
+// Hidden returns the rules of the fields, which the roles of the context cannot see
+func (p *FieldPolicy) Hidden(ctx context.Context, modelType reflect.Type) map[string]FieldRule {
+	roles := GetRoles(ctx, p.Roles)
+	hidden := make(map[string]FieldRule)
+	rules, _ := p.getRules(modelType)
+	for name, rule := range rules {
+		if !hasAny(roles, rule.Roles) {
+			hidden[name] = rule
+		}
+	}
+	return hidden
+}
+
+type hiddenFieldsKey struct{}
+
+// HiddenFields are the json names of the fields, which the user cannot see. SearchHandler adds it to the context,
+// and FieldPolicy.BeforeSearch sets the names, so that QueryBuilder.BuildQueryWithContext skips the keyword of them,
+// SearchBuilder.Aggregate skips the tag aggregates of them, and SearchCache keys the results by them.
+type HiddenFields struct {
+	Names map[string]bool
+}
+
+func WithHiddenFields(ctx context.Context, hidden *HiddenFields) context.Context {
+	return context.WithValue(ctx, hiddenFieldsKey{}, hidden)
+}
+func GetHiddenFields(ctx context.Context) map[string]bool {
+	if hidden, ok := ctx.Value(hiddenFieldsKey{}).(*HiddenFields); ok && hidden != nil {
+		return hidden.Names
+	}
+	return nil
+}
+
+// BeforeSearch rejects the search model, which selects, filters, sorts, excludes, facets or aggregates by the hidden fields,
+// selects the permitted fields if the client does not select the fields, and sets the hidden fields to the context
+func (p *FieldPolicy) BeforeSearch(modelType reflect.Type) BeforeSearchHook {
+	return func(ctx context.Context, m interface{}) error {
+		if _, err := p.getRules(modelType); err != nil {
+			return err
+		}
+		hidden := p.Hidden(ctx, modelType)
+		if len(hidden) == 0 {
+			return nil
+		}
+		holder, ok := ctx.Value(hiddenFieldsKey{}).(*HiddenFields)
+		if !ok || holder == nil {
+			return errors.New("field policy requires the context of SearchHandler or WithHiddenFields")
+		}
+		sm := GetSearchModel(m)
+		if sm == nil {
+			return nil
+		}
+		if err := CheckHiddenFields(m, sm, hidden, modelType); err != nil {
+			return err
+		}
+		if len(sm.Fields) == 0 {
+			fields := make([]string, 0)
+			for _, f := range GetJsonColumns(modelType) {
+				if _, ok := hidden[f]; !ok {
+					fields = append(fields, f)
+				}
+			}
+			if len(fields) == 0 {
+				return NewForbiddenError("no field is permitted")
+			}
+			sm.Fields = fields
+		}
+		names := make(map[string]bool)
+		for name := range hidden {
+			names[name] = true
+		}
+		holder.Names = names
+		return nil
+	}
+}
+
+// CheckHiddenFields returns the forbidden error if the search model selects, filters, sorts, excludes, facets or aggregates by a hidden field
+func CheckHiddenFields(m interface{}, sm *SearchModel, hidden map[string]FieldRule, modelType reflect.Type) error {
+	columns := make(map[string]bool)
+	for name := range hidden {
+		if i, _, column := GetFieldByJson(modelType, name); i >= 0 && len(column) > 0 {
+			columns[column] = true
+		}
+	}
+	value := reflect.Indirect(reflect.ValueOf(m))
+	if value.Kind() == reflect.Struct && value.Type() != reflect.TypeOf(SearchModel{}) {
+		for i, f := range GetMetadata(value.Type()).Fields {
+			if _, ok := hidden[f.Json]; !f.HasJson || !ok {
+				continue
+			}
+			if field := value.Field(i); !field.IsZero() && !(field.Kind() == reflect.Slice && field.Len() == 0) {
+				return NewForbiddenError("cannot filter by " + f.Json)
+			}
+		}
+	}
+	for _, name := range sm.Fields {
+		if _, ok := hidden[name]; ok {
+			return NewForbiddenError("cannot select " + name)
+		}
+	}
+	for _, s := range strings.Split(sm.Sort, ",") {
+		name := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(s), "+-"))
+		if _, ok := hidden[name]; ok || columns[name] {
+			return NewForbiddenError("cannot sort by " + name)
+		}
+	}
+	for name := range sm.Excluding {
+		if _, ok := hidden[name]; ok {
+			return NewForbiddenError("cannot filter by " + name)
+		}
+	}
+	for _, name := range sm.Facets {
+		if _, ok := hidden[name]; ok {
+			return NewForbiddenError("cannot facet by " + name)
+		}
+	}
+	for _, a := range sm.Aggregates {
+		if _, name, err := ParseAggregate(a); err == nil {
+			if _, ok := hidden[name]; ok || columns[name] {
+				return NewForbiddenError("cannot aggregate " + name)
+			}
+		}
+	}
+	if sm.Histogram != nil {
+		if _, ok := hidden[sm.Histogram.Field]; ok {
+			return NewForbiddenError("cannot build histogram by " + sm.Histogram.Field)
+		}
+	}
+	return nil
+}
+
+// hiddenKeyword returns the hidden field, which the keyword of the search model searches
+func hiddenKeyword(m interface{}, hidden map[string]bool) (string, bool) {
+	sm := GetSearchModel(m)
+	if len(hidden) == 0 || sm == nil || len(strings.TrimSpace(sm.Keyword)) == 0 {
+		return "", false
+	}
+	value := reflect.Indirect(reflect.ValueOf(m))
+	if value.Kind() != reflect.Struct {
+		return "", false
+	}
+	for i, f := range GetMetadata(value.Type()).Fields {
+		if f.HasKeyword && hidden[f.Json] && value.Field(i).Kind() == reflect.String && value.Field(i).Len() == 0 {
+			return f.Json, true
+		}
+	}
+	return "", false
+}
+
+// AfterSearch omits or masks the hidden fields of the results
+func (p *FieldPolicy) AfterSearch(modelType reflect.Type) AfterSearchHook {
+	return func(ctx context.Context, m interface{}, results interface{}, total int64) (interface{}, error) {
+		hidden := p.Hidden(ctx, modelType)
+		if len(hidden) > 0 {
+			MaskFields(results, hidden)
+		}
+		return results, nil
+	}
+}
+
+// AddTo adds BeforeSearch and AfterSearch hooks to the handler; it panics if a hidden field is not omitted by json
+func (p *FieldPolicy) AddTo(h *SearchHandler, modelType reflect.Type) {
+	if _, err := p.getRules(modelType); err != nil {
+		panic(err)
+	}
+	h.BeforeSearch = append(h.BeforeSearch, p.BeforeSearch(modelType))
+	h.AfterSearch = append(h.AfterSearch, p.AfterSearch(modelType))
+}
+
+func MaskFields(results interface{}, hidden map[string]FieldRule) {
+	values := reflect.Indirect(reflect.ValueOf(results))
+	if values.Kind() == reflect.Ptr {
+		values = reflect.Indirect(values)
+	}
+	if values.Kind() != reflect.Slice {
+		return
+	}
+	for i := 0; i < values.Len(); i++ {
+		v := reflect.Indirect(values.Index(i))
+		if v.Kind() == reflect.Interface {
+			v = reflect.Indirect(v.Elem())
+		}
+		if v.Kind() != reflect.Struct || !v.CanSet() {
+			continue
+		}
+		for name, rule := range hidden {
+			j, _ := findIndexByTagJson(v.Type(), name)
+			if j < 0 {
+				continue
+			}
+			f := v.Field(j)
+			if len(rule.Mask) > 0 && f.Kind() == reflect.String {
+				f.SetString(rule.Mask)
+			} else if len(rule.Mask) > 0 && f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.String {
+				s := rule.Mask
+				f.Set(reflect.ValueOf(&s))
+			} else {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		}
+	}
+}
+
+// GetJsonColumns returns the json names of the fields, which have gorm column
+func GetJsonColumns(modelType reflect.Type) []string {
+	names := make([]string, 0)
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		if _, ok := FindTag(field.Tag.Get("gorm"), "column"); !ok {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(name) > 0 && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+func GetRoles(ctx context.Context, key string) []string {
+	switch v := ctx.Value(key).(type) {
+	case []string:
+		return v
+	case string:
+		return splitTrim(v)
+	default:
+		return nil
+	}
+}
+func splitTrim(s string) []string {
+	r := make([]string, 0)
+	for _, x := range strings.Split(s, ",") {
+		x = strings.TrimSpace(x)
+		if len(x) > 0 {
+			r = append(r, x)
+		}
+	}
+	return r
+}
+func hasAny(a []string, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
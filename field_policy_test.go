@@ -0,0 +1,169 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type employee struct {
+	Id     string  `json:"id" gorm:"column:id"`
+	Name   string  `json:"name" gorm:"column:name"`
+	Email  string  `json:"email" gorm:"column:email" roles:"hr" mask:"***"`
+	Salary float64 `json:"salary,omitempty" gorm:"column:salary" roles:"hr" aggregate:"sum"`
+}
+type employeeSM struct {
+	*SearchModel
+	Name   string       `json:"name" gorm:"column:name" keyword:"prefix"`
+	Email  string       `json:"email" gorm:"column:email" keyword:"contain"`
+	Salary *NumberRange `json:"salary" gorm:"column:salary"`
+}
+
+func newEmployeeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	return newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		switch {
+		case strings.HasPrefix(query, "select sum("):
+			return rowsOf([]string{"a0"}, []driver.Value{5000.0}), nil
+		case isCountQuery(query):
+			return totalOf(1), nil
+		}
+		return rowsOf([]string{"id", "name", "email", "salary"}, []driver.Value{"1", "john", "john@test.com", 5000.0}), nil
+	})
+}
+func newEmployeeHandler(t *testing.T) (*SearchHandler, *fakeDB) {
+	db, f := newEmployeeDB(t)
+	modelType := reflect.TypeOf(employee{})
+	builder := NewDefaultSearchBuilder(db, "employees", modelType, nil)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(employeeSM{}), nil, nil)
+	NewFieldPolicy().AddTo(h, modelType)
+	return h, f
+}
+func searchAs(h *SearchHandler, roles string, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), Roles, roles))
+	w := httptest.NewRecorder()
+	h.Search(w, r)
+	return w
+}
+
+func TestFieldPolicyProjection(t *testing.T) {
+	h, f := newEmployeeHandler(t)
+	w := searchAs(h, "staff", http.MethodPost, "/employees/search", `{"keyword":"jo","limit":10}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body.String())
+	}
+	var result struct {
+		Results    []employee             `json:"results"`
+		Aggregates map[string]interface{} `json:"aggregates"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("the projection must not switch the result to csv: %v %s", err, w.Body.String())
+	}
+	if len(result.Results) != 1 || result.Results[0].Email != "***" {
+		t.Errorf("results = %+v", result.Results)
+	}
+	if strings.Contains(w.Body.String(), `"salary"`) {
+		t.Errorf("the hidden field must be omitted: %s", w.Body.String())
+	}
+	if result.Aggregates != nil {
+		t.Errorf("the tag aggregate of the hidden field must not be returned: %v", result.Aggregates)
+	}
+	for _, q := range f.Queries() {
+		if strings.Contains(q.Query, "salary") || strings.Contains(q.Query, "email") {
+			t.Errorf("the query must not use the hidden fields: %q", q.Query)
+		}
+	}
+	if q := f.Queries()[0]; q.Query != "select id,name from employees where (name like ?) limit 10 offset 0 " || !reflect.DeepEqual(q.Args, []interface{}{"jo%"}) {
+		t.Errorf("query = %q %v", q.Query, q.Args)
+	}
+}
+
+func TestFieldPolicyRejectsHiddenFilters(t *testing.T) {
+	h, f := newEmployeeHandler(t)
+	for _, body := range []string{
+		`{"salary":{"min":1000}}`,
+		`{"email":"john"}`,
+		`{"sort":"-salary"}`,
+		`{"sort":"name,email"}`,
+		`{"excluding":{"email":["a@test.com"]}}`,
+		`{"histogram":{"field":"salary","width":100}}`,
+		`{"facets":["salary"]}`,
+		`{"aggregates":["avg(salary)"]}`,
+		`{"fields":["id","salary"]}`,
+	} {
+		if w := searchAs(h, "staff", http.MethodPost, "/employees/search", body); w.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d %s", body, w.Code, w.Body.String())
+		}
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("queries = %+v", f.Queries())
+	}
+	if w := searchAs(h, "hr", http.MethodPost, "/employees/search", `{"salary":{"min":1000},"sort":"-salary"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"sum(salary)":5000`) {
+		t.Errorf("hr: status %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCsvBySelectedFields(t *testing.T) {
+	h, _ := newEmployeeHandler(t)
+	w := searchAs(h, "staff", http.MethodPost, "/employees/search", `{"fields":["id","name"]}`)
+	if w.Code != http.StatusOK || strings.HasPrefix(w.Body.String(), "{") {
+		t.Errorf("the fields selected by the client are returned as csv: %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "5000") {
+		t.Errorf("the hidden field must not be returned: %s", w.Body.String())
+	}
+}
+
+func TestFieldPolicyKeywordOfBuildQuery(t *testing.T) {
+	db, f := newEmployeeDB(t)
+	modelType := reflect.TypeOf(employee{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("employees", modelType, GetDriver(db)).BuildQuery)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(employeeSM{}), nil, nil)
+	NewFieldPolicy().AddTo(h, modelType)
+	if w := searchAs(h, "staff", http.MethodPost, "/employees/search", `{"keyword":"jo"}`); w.Code != http.StatusForbidden {
+		t.Errorf("BuildQuery cannot skip the keyword of the hidden fields: status %d %s", w.Code, w.Body.String())
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("queries = %+v", f.Queries())
+	}
+	if w := searchAs(h, "hr", http.MethodPost, "/employees/search", `{"keyword":"jo"}`); w.Code != http.StatusOK {
+		t.Errorf("hr: status %d %s", w.Code, w.Body.String())
+	}
+	if w := searchAs(h, "staff", http.MethodPost, "/employees/search", `{"name":"jo"}`); w.Code != http.StatusOK {
+		t.Errorf("staff without keyword: status %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFieldPolicyRules(t *testing.T) {
+	p := NewFieldPolicy()
+	modelType := reflect.TypeOf(employee{})
+	rules := p.GetRules(modelType)
+	if len(rules) != 2 || rules["email"].Mask != "***" || !reflect.DeepEqual(rules["salary"].Roles, []string{"hr"}) {
+		t.Fatalf("rules = %+v", rules)
+	}
+	delete(rules, "salary")
+	p.Register(modelType, "name", FieldRule{Roles: []string{"admin"}})
+	if rules := p.GetRules(modelType); len(rules) != 3 {
+		t.Errorf("the cached rules must be copied and cleared by Register: %+v", rules)
+	}
+	if err := p.BeforeSearch(modelType)(context.Background(), &employeeSM{SearchModel: &SearchModel{}}); err == nil {
+		t.Error("the field policy without the hidden fields of the context must fail")
+	}
+
+	type account struct {
+		Id      string  `json:"id" gorm:"column:id"`
+		Balance float64 `json:"balance" gorm:"column:balance" roles:"admin"`
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("the hidden field, which is not omitted, must panic")
+		}
+	}()
+	NewFieldPolicy().AddTo(NewSearchHandler(nil, reflect.TypeOf(employeeSM{}), nil, nil), reflect.TypeOf(account{}))
+}
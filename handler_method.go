@@ -1,30 +1,276 @@
 package search
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
 
 func (c *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
-	searchModel, x, err := BuildSearchModel(r, c.searchModelType, c.isExtendedSearchModelType, c.userId, c.searchModelParamIndex, c.searchModelIndex, c.paramIndex)
-	if err != nil {
-		http.Error(w, "cannot decode search model: "+err.Error(), http.StatusBadRequest)
+	start := time.Now()
+	rw := &responseWriter{ResponseWriter: w}
+	w = rw
+	var searchModel, models interface{}
+	var count int64
+	if c.Audit != nil {
+		defer func() { c.audit(r, start, searchModel, models, count, rw) }()
+	}
+	defer c.recover(w, r)
+	info := &TotalInfo{Exact: true}
+	r = r.WithContext(WithTotalInfo(c.context(r.Context()), info))
+	searchModel, x, ok := c.buildSearchModel(w, r)
+	if !ok {
+		return
+	}
+	if c.HeaderPaging {
+		if sm := GetSearchModel(searchModel); sm != nil && (len(sm.Facets) > 0 || len(sm.Aggregates) > 0 || sm.Histogram != nil) {
+			respondProblem(w, r, NewBadRequestError("facets, aggregates and histogram are not supported by header paging"), c.Error, c.Resource, c.Action, c.Log)
+			return
+		}
+	}
+	// the result is csv if the client selects the fields, not if a hook, such as FieldPolicy.BeforeSearch, sets them
+	csv := false
+	if sm := GetSearchModel(searchModel); sm != nil {
+		csv = c.quickSearch && x == 1 && len(sm.Fields) > 0
+	}
+	var err error
+	if r.Method == http.MethodHead {
+		count, err = c.count(r, searchModel)
+		if err != nil {
+			respondProblem(w, r, err, c.Error, c.Resource, c.Action, c.Log)
+			return
+		}
+		w.Header().Set(HeaderTotalCount, strconv.FormatInt(count, 10))
+		w.WriteHeader(http.StatusOK)
+		if c.Log != nil {
+			c.Log(r.Context(), c.Resource, c.Action, true, "")
+		}
 		return
 	}
-	models, count, err := c.search(r.Context(), searchModel)
+	models, count, err = c.runSearch(r.Context(), searchModel)
 	if err != nil {
-		respondError(w, r, http.StatusInternalServerError, InternalServerError, c.Error, c.Resource, "search", err, c.Log)
+		respondProblem(w, r, err, c.Error, c.Resource, "search", c.Log)
 		return
 	}
 	pageIndex, pageSize, firstPageSize, fs, err := ExtractFullSearch(searchModel)
-	result, isLastPage := BuildResultMap(models, count, pageIndex, pageSize, firstPageSize, c.Config)
+	if c.HeaderPaging {
+		if info.Unknown {
+			SetNextPagingHeaders(w, r, info.HasNext, pageIndex, pageSize)
+		} else {
+			SetPagingHeaders(w, r, count, pageIndex, pageSize, firstPageSize)
+		}
+		c.succeed(w, r, models)
+		return
+	}
+	var result map[string]interface{}
+	var isLastPage bool
+	if info.Unknown {
+		result, isLastPage = BuildNextResultMap(models, info.HasNext, c.Config)
+	} else {
+		result, isLastPage = BuildResultMap(models, count, pageIndex, pageSize, firstPageSize, c.Config)
+		if len(info.Strategy) > 0 {
+			result[c.Config.Exact] = info.Exact
+		}
+	}
+	if c.Facets != nil {
+		if sm := GetSearchModel(searchModel); sm != nil && len(sm.Facets) > 0 {
+			facets, err := c.Facets(r.Context(), searchModel)
+			if err != nil {
+				respondProblem(w, r, err, c.Error, c.Resource, "facets", c.Log)
+				return
+			}
+			result[c.Config.Facets] = facets
+		}
+	}
+	if c.Aggregate != nil {
+		aggregates, err := c.Aggregate(r.Context(), searchModel)
+		if err != nil {
+			respondProblem(w, r, err, c.Error, c.Resource, "aggregate", c.Log)
+			return
+		}
+		if len(aggregates) > 0 {
+			result[c.Config.Aggregates] = aggregates
+		}
+	}
+	if c.Histogram != nil {
+		if sm := GetSearchModel(searchModel); sm != nil && sm.Histogram != nil {
+			buckets, err := c.Histogram(r.Context(), searchModel)
+			if err != nil {
+				respondProblem(w, r, err, c.Error, c.Resource, "histogram", c.Log)
+				return
+			}
+			result[c.Config.Histogram] = buckets
+		}
+	}
 	if x == -1 {
-		succeed(w, r, http.StatusOK, result, c.Log, c.Resource, c.Action)
-	} else if c.quickSearch && x == 1 {
+		c.succeed(w, r, result)
+	} else if csv {
 		result1, ok := ResultToCsv(fs, models, count, isLastPage, c.embedField)
 		if ok {
-			succeed(w, r, http.StatusOK, result1, c.Log, c.Resource, c.Action)
+			c.succeed(w, r, result1)
 		} else {
-			succeed(w, r, http.StatusOK, result, c.Log, c.Resource, c.Action)
+			c.succeed(w, r, result)
 		}
 	} else {
-		succeed(w, r, http.StatusOK, result, c.Log, c.Resource, c.Action)
+		c.succeed(w, r, result)
 	}
 }
+
+func (c *SearchHandler) Count(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &responseWriter{ResponseWriter: w}
+	w = rw
+	var searchModel interface{}
+	var count int64
+	if c.Audit != nil {
+		defer func() { c.audit(r, start, searchModel, nil, count, rw) }()
+	}
+	defer c.recover(w, r)
+	info := &TotalInfo{Exact: true}
+	r = r.WithContext(WithTotalInfo(c.context(r.Context()), info))
+	searchModel, _, ok := c.buildSearchModel(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	count, err = c.count(r, searchModel)
+	if err != nil {
+		respondProblem(w, r, err, c.Error, c.Resource, "count", c.Log)
+		return
+	}
+	result := make(map[string]interface{})
+	result[c.Config.Total] = count
+	if len(info.Strategy) > 0 {
+		result[c.Config.Exact] = info.Exact
+	}
+	c.succeed(w, r, result)
+}
+func (c *SearchHandler) context(ctx context.Context) context.Context {
+	ctx = WithHiddenFields(WithAction(WithResource(ctx, c.Resource), c.Action), &HiddenFields{})
+	if c.Instrument != nil {
+		ctx = WithInstrument(ctx, c.Instrument)
+	}
+	return ctx
+}
+func (c *SearchHandler) succeed(w http.ResponseWriter, r *http.Request, result interface{}) {
+	_, end := StartSpan(r.Context(), StageEncode)
+	succeed(w, r, http.StatusOK, result, c.Log, c.Resource, c.Action)
+	end(0, nil)
+}
+func (c *SearchHandler) buildSearchModel(w http.ResponseWriter, r *http.Request) (interface{}, int, bool) {
+	_, end := StartSpan(r.Context(), StageDecode)
+	searchModel, x, err := DecodeSearchModel(r, c.searchModelType, c.isExtendedSearchModelType, c.searchModelParamIndex, c.searchModelIndex, c.paramIndex)
+	end(0, err)
+	if err != nil {
+		respondProblem(w, r, NewBadRequestError("cannot decode search model: "+err.Error()), c.Error, c.Resource, c.Action, c.Log)
+		return nil, x, false
+	}
+	// the paging policy runs first, so that Validate checks the limit clamped by the policy
+	paged := false
+	if c.Paging != nil {
+		if sm := GetSearchModel(searchModel); sm != nil {
+			if err := ApplyPaging(sm, *c.Paging); err != nil {
+				respondProblem(w, r, err, c.Error, c.Resource, c.Action, c.Log)
+				return nil, x, false
+			}
+			paged = true
+		}
+	}
+	if c.Validate != nil {
+		errs := c.Validate(searchModel)
+		if len(errs) > 0 {
+			respondProblem(w, r, NewBadRequestError("invalid search model", errs...), c.Error, c.Resource, c.Action, c.Log)
+			return nil, x, false
+		}
+	}
+	if paged {
+		GetSearchModel(searchModel).CurrentUserId = GetUserId(r, c.userId)
+		return searchModel, x, true
+	}
+	SetUserId(searchModel, GetUserId(r, c.userId))
+	return searchModel, x, true
+}
+func (c *SearchHandler) count(r *http.Request, searchModel interface{}) (int64, error) {
+	if c.Counter != nil {
+		if err := c.before(r.Context(), searchModel); err != nil {
+			return 0, err
+		}
+		if len(c.middlewares) == 0 {
+			return c.Counter(r.Context(), searchModel)
+		}
+		counter := SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+			total, err := c.Counter(ctx, m)
+			return nil, total, err
+		})
+		_, total, err := Chain(counter, c.middlewares...).Search(WithCountOnly(r.Context()), searchModel)
+		return total, err
+	}
+	_, count, err := c.runSearch(r.Context(), searchModel)
+	return count, err
+}
+func (c *SearchHandler) runSearch(ctx context.Context, searchModel interface{}) (interface{}, int64, error) {
+	if err := c.before(ctx, searchModel); err != nil {
+		return nil, 0, err
+	}
+	ctx2, end := StartSpan(ctx, StageSearch)
+	models, count, err := c.search(ctx2, searchModel)
+	end(GetResultCount(models), err)
+	if err != nil {
+		return models, count, err
+	}
+	for _, after := range c.AfterSearch {
+		models, err = after(ctx, searchModel, models, count)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return models, count, nil
+}
+func (c *SearchHandler) before(ctx context.Context, searchModel interface{}) error {
+	for _, before := range c.BeforeSearch {
+		if err := before(ctx, searchModel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recover keeps the panic of search from crashing the request; the panic is a bug, so it is logged with the stack
+func (c *SearchHandler) recover(w http.ResponseWriter, r *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := RecoverToError(v)
+	msg := fmt.Sprintf("panic in %s %s: %s\n%s", r.Method, r.URL.Path, err.Error(), debug.Stack())
+	if c.Error != nil {
+		c.Error(r.Context(), msg)
+	} else {
+		log.Println(msg)
+	}
+	if rw, ok := w.(*responseWriter); ok && rw.written {
+		return
+	}
+	respondProblem(w, r, err, nil, c.Resource, c.Action, c.Log)
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+	status  int
+	err     error
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.written = true
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeadCountsOnly(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(testUserSM{}), nil, nil)
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodHead, "/users?status=A", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("status %d, body %q", w.Code, w.Body.String())
+	}
+	if total := w.Header().Get(HeaderTotalCount); total != "25" {
+		t.Errorf("X-Total-Count = %q, want 25", total)
+	}
+	queries := f.Queries()
+	if len(queries) != 1 || !isCountQuery(queries[0].Query) {
+		t.Errorf("HEAD must run the count query only: %+v", queries)
+	}
+}
+
+func TestCount(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(testUserSM{}), nil, nil)
+	w := httptest.NewRecorder()
+	h.Count(w, httptest.NewRequest(http.MethodGet, "/users/count?status=A", nil))
+	var result map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if result["total"] != 25.0 {
+		t.Errorf("result = %v", result)
+	}
+	if queries := f.Queries(); len(queries) != 1 || queries[0].Query != "select count(*) as total  from users where status in (?)" {
+		t.Errorf("queries = %+v", queries)
+	}
+}
+
+func TestNewSearchHandlerWithBuilder(t *testing.T) {
+	builder, _ := newUserDB(t, 25)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(testUserSM{}), nil, nil)
+	if h.Counter == nil || h.Facets == nil || h.Aggregate == nil || h.Histogram == nil || h.Validate == nil {
+		t.Errorf("the options of the builder are not set: %+v", h)
+	}
+	h = NewSearchHandlerWithOptions(builder.Search, reflect.TypeOf(testUserSM{}), HandlerOptions{Counter: builder.Count}, nil, nil)
+	if h.Counter == nil || h.Facets != nil || h.Validate != nil {
+		t.Errorf("only Counter must be set: %+v", h)
+	}
+}
+
+func TestHeaderPagingRejectsFacets(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(testUserSM{}), nil, nil)
+	h.HeaderPaging = true
+	for _, u := range []string{"/users?facets=status", "/users?aggregates=sum(salary)", "/users?histogram.field=salary&histogram.interval=100"} {
+		w := httptest.NewRecorder()
+		h.Search(w, httptest.NewRequest(http.MethodGet, u, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "header paging") {
+			t.Errorf("%s: status %d, body %s", u, w.Code, w.Body.String())
+		}
+	}
+	if queries := f.Queries(); len(queries) != 0 {
+		t.Errorf("rejected requests must not query: %+v", queries)
+	}
+}
@@ -6,18 +6,8 @@ import (
 )
 
 func SetUserId(sm interface{}, currentUserId string) {
-	if s, ok := sm.(*SearchModel); ok { // Is SearchModel struct
+	if s := GetSearchModel(sm); s != nil {
 		RepairSearchModel(s, currentUserId)
-	} else { // Is extended from SearchModel struct
-		value := reflect.Indirect(reflect.ValueOf(sm))
-		numField := value.NumField()
-		for i := 0; i < numField; i++ {
-			// Find SearchModel field of extended struct
-			if s, ok := value.Field(i).Interface().(*SearchModel); ok {
-				RepairSearchModel(s, currentUserId)
-				break
-			}
-		}
 	}
 }
 func CreateSearchModel(searchModelType reflect.Type, isExtendedSearchModelType bool) interface{} {
@@ -40,7 +30,7 @@ func CreateSearchModel(searchModelType reflect.Type, isExtendedSearchModelType b
 func FindSearchModelIndex(searchModelType reflect.Type) int {
 	numField := searchModelType.NumField()
 	for i := 0; i < numField; i++ {
-		if searchModelType.Field(i).Type == reflect.TypeOf(&SearchModel{}) {
+		if t := searchModelType.Field(i).Type; t == reflect.TypeOf(&SearchModel{}) || t == reflect.TypeOf(SearchModel{}) {
 			return i
 		}
 	}
@@ -70,7 +60,7 @@ func RepairSearchModel(searchModel *SearchModel, currentUserId string) {
 }
 
 func ExtractFullSearch(m interface{}) (int64, int64, int64, []string, error) {
-	if sModel, ok := m.(*SearchModel); ok {
+	if sModel := GetSearchModel(m); sModel != nil {
 		return sModel.Page, sModel.Limit, sModel.FirstLimit, sModel.Fields, nil
 	} else {
 		value := reflect.Indirect(reflect.ValueOf(m))
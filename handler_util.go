@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
-	"strconv"
 	"strings"
 )
 
@@ -27,49 +26,8 @@ func BuildResourceName(s string) string {
 	return s3
 }
 func UrlToModel(searchModel interface{}, params url.Values, searchModelParamIndex map[string]int, searchModelIndex int, paramIndex map[string]int) interface{} {
-	value := reflect.Indirect(reflect.ValueOf(searchModel))
-	if value.Kind() == reflect.Ptr {
-		value = reflect.Indirect(value)
-	}
-
-	for paramKey, valueArr := range params {
-		paramValue := ""
-		if len(valueArr) > 0 {
-			paramValue = valueArr[0]
-		}
-		if err, field := FindField(value, paramKey, searchModelParamIndex, searchModelIndex, paramIndex); err == nil {
-			kind := field.Kind()
-
-			var v interface{}
-			// Need handle more case of kind
-			if kind == reflect.Int {
-				v, _ = strconv.Atoi(paramValue)
-			} else if kind == reflect.Int64 {
-				v, _ = strconv.ParseInt(paramValue, 10, 64)
-			} else if kind == reflect.String {
-				v = paramValue
-			} else if kind == reflect.Slice {
-				sliceKind := reflect.TypeOf(field.Interface()).Elem().Kind()
-				if sliceKind == reflect.String {
-					v = strings.Split(paramValue, ",")
-				} else {
-					log.Println("Unhandled slice kind:", kind)
-					continue
-				}
-			} else if kind == reflect.Struct {
-				newModel := reflect.New(reflect.Indirect(field).Type()).Interface()
-				if errDecode := json.Unmarshal([]byte(paramValue), newModel); errDecode != nil {
-					panic(errDecode)
-				}
-				v = newModel
-			} else {
-				log.Println("Unhandled kind:", kind)
-				continue
-			}
-			field.Set(reflect.Indirect(reflect.ValueOf(v)))
-		} else {
-			log.Println(err)
-		}
+	if err := DecodeUrl(searchModel, params, searchModelParamIndex, searchModelIndex, paramIndex); err != nil {
+		log.Println(err)
 	}
 	return searchModel
 }
@@ -100,34 +58,55 @@ func BuildParamIndex(searchModelType reflect.Type) map[string]int {
 }
 
 func BuildSearchModel(r *http.Request, searchModelType reflect.Type, isExtendedSearchModelType bool, userIdName string, searchModelParamIndex map[string]int, searchModelIndex int, paramIndex map[string]int) (interface{}, int, error) {
+	searchModel, x, err := DecodeSearchModel(r, searchModelType, isExtendedSearchModelType, searchModelParamIndex, searchModelIndex, paramIndex)
+	if err != nil {
+		return nil, x, err
+	}
+	SetUserId(searchModel, GetUserId(r, userIdName))
+	return searchModel, x, nil
+}
+func DecodeSearchModel(r *http.Request, searchModelType reflect.Type, isExtendedSearchModelType bool, searchModelParamIndex map[string]int, searchModelIndex int, paramIndex map[string]int) (interface{}, int, error) {
 	var searchModel = CreateSearchModel(searchModelType, isExtendedSearchModelType)
 	method := r.Method
 	x := 1
-	if method == http.MethodGet {
+	if method == http.MethodGet || method == http.MethodHead {
 		ps := r.URL.Query()
 		fs := ps.Get("fields")
 		if len(fs) == 0 {
 			x = -1
 		}
-		UrlToModel(searchModel, ps, searchModelParamIndex, searchModelIndex, paramIndex)
+		if err := DecodeUrl(searchModel, ps, searchModelParamIndex, searchModelIndex, paramIndex); err != nil {
+			return nil, x, err
+		}
 	} else if method == http.MethodPost {
 		if err := json.NewDecoder(r.Body).Decode(&searchModel); err != nil {
 			return nil, x, err
 		}
-	}
-	userId := ""
-	if len(userId) == 0 {
-		u := r.Context().Value(userIdName)
-		if u != nil {
-			u2, ok2 := u.(string)
-			if ok2 {
-				userId = u2
-			}
+		// the page and limit of the query string, such as the ones of the Link header, override the body
+		if err := DecodeUrl(searchModel, pagingParams(r.URL.Query()), searchModelParamIndex, searchModelIndex, paramIndex); err != nil {
+			return nil, x, err
 		}
 	}
-	SetUserId(searchModel, userId)
 	return searchModel, x, nil
 }
+func pagingParams(ps url.Values) url.Values {
+	params := url.Values{}
+	for _, key := range []string{ParamPage, ParamLimit} {
+		if v, ok := ps[key]; ok {
+			params[key] = v
+		}
+	}
+	return params
+}
+func GetUserId(r *http.Request, userIdName string) string {
+	u := r.Context().Value(userIdName)
+	if u != nil {
+		if userId, ok := u.(string); ok {
+			return userId
+		}
+	}
+	return ""
+}
 func BuildResultMap(models interface{}, count int64, pageIndex int64, pageSize int64, firstPageSize int64, config SearchResultConfig) (map[string]interface{}, bool) {
 	result := make(map[string]interface{})
 	isLastPage := IsLastPage(models, count, pageIndex, pageSize, firstPageSize)
@@ -139,6 +118,17 @@ func BuildResultMap(models interface{}, count int64, pageIndex int64, pageSize i
 	result[config.Results] = models
 	return result, isLastPage
 }
+
+// BuildNextResultMap builds the result map with hasNext instead of total, when the total is unknown
+func BuildNextResultMap(models interface{}, hasNext bool, config SearchResultConfig) (map[string]interface{}, bool) {
+	result := make(map[string]interface{})
+	result[config.HasNext] = hasNext
+	if !hasNext {
+		result[config.LastPage] = true
+	}
+	result[config.Results] = models
+	return result, !hasNext
+}
 func ResultToCsv(fields []string, models interface{}, count int64, isLastPage bool, embedField string) (string, bool) {
 	if len(fields) > 0 {
 		result1 := ToCsv(fields, models, count, isLastPage, embedField)
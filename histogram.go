@@ -0,0 +1,281 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+	bucketLayout  = "2006-01-02"
+	// the max number of the buckets of a histogram, if MaxBuckets of SearchBuilder is 0
+	MaxBucketsDefault = 1000
+)
+
+type Histogram struct {
+	Field    string   `mapstructure:"field" json:"field,omitempty" gorm:"column:field" bson:"field,omitempty" dynamodbav:"field,omitempty" firestore:"field,omitempty"`
+	Interval string   `mapstructure:"interval" json:"interval,omitempty" gorm:"column:interval" bson:"interval,omitempty" dynamodbav:"interval,omitempty" firestore:"interval,omitempty"`
+	Width    *float64 `mapstructure:"width" json:"width,omitempty" gorm:"column:width" bson:"width,omitempty" dynamodbav:"width,omitempty" firestore:"width,omitempty"`
+}
+type Bucket struct {
+	Key   interface{} `mapstructure:"key" json:"key" gorm:"column:key" bson:"key" dynamodbav:"key" firestore:"key"`
+	Count int64       `mapstructure:"count" json:"count" gorm:"column:count" bson:"count" dynamodbav:"count" firestore:"count"`
+}
+
+func (b *SearchBuilder) Histogram(ctx context.Context, m interface{}) ([]Bucket, error) {
+	sm := GetSearchModel(m)
+	if sm == nil || sm.Histogram == nil {
+		return nil, nil
+	}
+	return BuildHistogram(ctx, b.db(), m, *sm.Histogram, b.ModelType, b.Build, b.driver(), b.MaxBuckets)
+}
+
+// BuildHistogram returns the buckets of the filtered set; more than maxBuckets buckets are rejected with 400
+func BuildHistogram(ctx context.Context, db Querier, m interface{}, h Histogram, modelType reflect.Type, buildQuery func(ctx context.Context, sm interface{}) (string, []interface{}, error), driver string, maxBuckets int) ([]Bucket, error) {
+	i, _, column := GetFieldByJson(modelType, h.Field)
+	if i < 0 || len(column) == 0 {
+		return nil, NewUnknownFieldError(h.Field)
+	}
+	if maxBuckets <= 0 {
+		maxBuckets = MaxBucketsDefault
+	}
+	if h.Width == nil && !IsValidInterval(h.Interval) {
+		return nil, NewBadRequestError("histogram interval " + h.Interval + " is not supported")
+	}
+	query, params, err := buildQuery(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	histogramQuery, err := BuildHistogramQuery(query, h, column, driver)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := GetQuerier(ctx, db).QueryContext(ctx, histogramQuery, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	buckets := make([]Bucket, 0)
+	for rows.Next() {
+		var k interface{}
+		var c int64
+		if err := rows.Scan(&k, &c); err != nil {
+			return nil, err
+		}
+		if len(buckets) >= maxBuckets {
+			return nil, tooManyBuckets(maxBuckets)
+		}
+		if h.Width != nil {
+			buckets = append(buckets, Bucket{Key: ToNumber(k), Count: c})
+		} else {
+			key, err := ToDateKey(k)
+			if err != nil {
+				return nil, err
+			}
+			buckets = append(buckets, Bucket{Key: key, Count: c})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if h.Width == nil {
+		if start, end, ok := GetDateRange(m, h.Field); ok {
+			return FillDateBuckets(buckets, start, end, h.Interval, maxBuckets)
+		}
+	}
+	return buckets, nil
+}
+
+func BuildHistogramQuery(sql string, h Histogram, column string, driver string) (string, error) {
+	var expr string
+	if h.Width != nil {
+		if *h.Width <= 0 {
+			return "", NewBadRequestError("histogram width must be greater than 0")
+		}
+		expr = "floor(" + column + "/" + strconv.FormatFloat(*h.Width, 'f', -1, 64) + ")*" + strconv.FormatFloat(*h.Width, 'f', -1, 64)
+	} else {
+		e, err := BuildDateTrunc(column, h.Interval, driver)
+		if err != nil {
+			return "", err
+		}
+		expr = e
+	}
+	i := strings.Index(sql, "select ")
+	j := strings.Index(sql, " from ")
+	if i < 0 || j < 0 {
+		return "", errors.New("cannot build histogram query")
+	}
+	k := strings.Index(sql, " order by ")
+	if k < 0 {
+		k = len(sql)
+	}
+	if strings.Index(sql, " distinct ") > 0 {
+		expr = strings.Replace(expr, column, "main."+column, -1)
+		return "select " + expr + " as bucket, count(*) as total from (" + sql[i:k] + ") as main group by " + expr + " order by 1", nil
+	}
+	return "select " + expr + " as bucket, count(*) as total" + sql[j:k] + " group by " + expr + " order by 1", nil
+}
+
+func BuildDateTrunc(column string, interval string, driver string) (string, error) {
+	switch driver {
+	case DriverPostgres:
+		switch interval {
+		case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+			return "date_trunc('" + interval + "', " + column + ")", nil
+		}
+	case DriverMysql:
+		switch interval {
+		case IntervalDay:
+			return "DATE_FORMAT(" + column + ", '%Y-%m-%d')", nil
+		case IntervalWeek:
+			return "DATE_FORMAT(DATE_SUB(" + column + ", INTERVAL WEEKDAY(" + column + ") DAY), '%Y-%m-%d')", nil
+		case IntervalMonth:
+			return "DATE_FORMAT(" + column + ", '%Y-%m-01')", nil
+		case IntervalYear:
+			return "DATE_FORMAT(" + column + ", '%Y-01-01')", nil
+		}
+	case DriverOracle:
+		switch interval {
+		case IntervalDay:
+			return "TRUNC(" + column + ", 'DD')", nil
+		case IntervalWeek:
+			return "TRUNC(" + column + ", 'IW')", nil
+		case IntervalMonth:
+			return "TRUNC(" + column + ", 'MM')", nil
+		case IntervalYear:
+			return "TRUNC(" + column + ", 'YYYY')", nil
+		}
+	case DriverMssql:
+		switch interval {
+		case IntervalDay:
+			return "DATEADD(day, DATEDIFF(day, 0, " + column + "), 0)", nil
+		case IntervalWeek:
+			return "DATEADD(day, (DATEDIFF(day, 0, " + column + ")/7)*7, 0)", nil
+		case IntervalMonth:
+			return "DATEADD(month, DATEDIFF(month, 0, " + column + "), 0)", nil
+		case IntervalYear:
+			return "DATEADD(year, DATEDIFF(year, 0, " + column + "), 0)", nil
+		}
+	default:
+		return "", errors.New("histogram is not supported for driver " + driver)
+	}
+	return "", NewBadRequestError("histogram interval " + interval + " is not supported")
+}
+
+func ToDateKey(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(bucketLayout), nil
+	case []byte:
+		return ToDateKey(string(t))
+	case string:
+		if len(t) >= len(bucketLayout) {
+			if d, err := time.Parse(bucketLayout, t[0:len(bucketLayout)]); err == nil {
+				return d.Format(bucketLayout), nil
+			}
+		}
+		return "", errors.New("cannot parse date bucket " + t)
+	case nil:
+		return "", nil
+	default:
+		return "", errors.New("cannot parse date bucket")
+	}
+}
+
+// GetDateRange finds the DateRange or TimeRange filter of the search model by json name
+func GetDateRange(m interface{}, jsonName string) (time.Time, time.Time, bool) {
+	value := reflect.Indirect(reflect.ValueOf(m))
+	if value.Kind() != reflect.Struct {
+		return time.Time{}, time.Time{}, false
+	}
+	i, _ := findIndexByTagJson(value.Type(), jsonName)
+	if i < 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	var start, end *time.Time
+	switch r := value.Field(i).Interface().(type) {
+	case DateRange:
+		start, end = r.StartDate, r.EndDate
+	case *DateRange:
+		if r != nil {
+			start, end = r.StartDate, r.EndDate
+		}
+	case TimeRange:
+		start, end = r.StartTime, r.EndTime
+	case *TimeRange:
+		if r != nil {
+			start, end = r.StartTime, r.EndTime
+		}
+	}
+	if start == nil || end == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return *start, *end, true
+}
+func IsValidInterval(interval string) bool {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+		return true
+	default:
+		return false
+	}
+}
+func tooManyBuckets(maxBuckets int) error {
+	return NewBadRequestError("histogram has more than " + strconv.Itoa(maxBuckets) + " buckets, use a larger interval, width or range")
+}
+func TruncateDate(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	switch interval {
+	case IntervalWeek:
+		d0 := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return d0.AddDate(0, 0, -((int(d0.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+}
+func NextDate(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	case IntervalYear:
+		return t.AddDate(1, 0, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// FillDateBuckets adds the empty buckets between start and end; the range of more than maxBuckets buckets is rejected with 400
+func FillDateBuckets(buckets []Bucket, start time.Time, end time.Time, interval string, maxBuckets int) ([]Bucket, error) {
+	if !IsValidInterval(interval) {
+		return nil, NewBadRequestError("histogram interval " + interval + " is not supported")
+	}
+	counts := make(map[string]int64)
+	for _, b := range buckets {
+		if k, ok := b.Key.(string); ok {
+			counts[k] = b.Count
+		}
+	}
+	filled := make([]Bucket, 0)
+	last := TruncateDate(end, interval)
+	for t := TruncateDate(start, interval); !t.After(last); t = NextDate(t, interval) {
+		if len(filled) >= maxBuckets {
+			return nil, tooManyBuckets(maxBuckets)
+		}
+		k := t.Format(bucketLayout)
+		filled = append(filled, Bucket{Key: k, Count: counts[k]})
+	}
+	return filled, nil
+}
@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildHistogramQuery(t *testing.T) {
+	width := 100.0
+	query, err := BuildHistogramQuery("select id from users where status = ? order by id", Histogram{Field: "salary", Width: &width}, "salary", DriverMysql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "select floor(salary/100)*100 as bucket, count(*) as total from users where status = ? group by floor(salary/100)*100 order by 1"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	query, err = BuildHistogramQuery("select distinct id, created from users", Histogram{Field: "created", Interval: IntervalMonth}, "created", DriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "select date_trunc('month', main.created) as bucket, count(*) as total from (select distinct id, created from users) as main group by date_trunc('month', main.created) order by 1"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	zero := 0.0
+	if _, err := BuildHistogramQuery("select id from users", Histogram{Width: &zero}, "salary", DriverMysql); err == nil {
+		t.Error("zero width must fail")
+	}
+	if _, err := BuildHistogramQuery("select id from users", Histogram{Interval: "hour"}, "created", DriverPostgres); err == nil {
+		t.Error("unknown interval must fail")
+	}
+	if _, err := BuildDateTrunc("created", IntervalDay, DriverNotSupport); err == nil {
+		t.Error("unknown driver must fail")
+	}
+}
+
+func TestFillDateBuckets(t *testing.T) {
+	start := time.Date(2024, 1, 30, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	buckets, err := FillDateBuckets([]Bucket{{Key: "2024-01-31", Count: 4}}, start, end, IntervalDay, 4)
+	want := []Bucket{{Key: "2024-01-30"}, {Key: "2024-01-31", Count: 4}, {Key: "2024-02-01"}, {Key: "2024-02-02"}}
+	if err != nil || !reflect.DeepEqual(buckets, want) {
+		t.Errorf("buckets = %v, %v, want %v", buckets, err, want)
+	}
+	if _, err := FillDateBuckets(nil, start, end, IntervalDay, 3); err == nil {
+		t.Error("more than max buckets must fail")
+	}
+	if _, err := FillDateBuckets(nil, start, end, "", 10); err == nil {
+		t.Error("empty interval must fail")
+	}
+	if week := TruncateDate(time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC), IntervalWeek); week.Format(bucketLayout) != "2024-01-29" {
+		t.Errorf("week of Sunday = %s, want Monday 2024-01-29", week.Format(bucketLayout))
+	}
+	for _, v := range []interface{}{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "2024-01-02 00:00:00", []byte("2024-01-02T00:00:00Z")} {
+		if k, err := ToDateKey(v); err != nil || k != "2024-01-02" {
+			t.Errorf("ToDateKey(%v) = %q, %v", v, k, err)
+		}
+	}
+}
+
+func TestNumericHistogram(t *testing.T) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		return rowsOf([]string{"bucket", "total"}, []driver.Value{[]byte("0"), int64(2)}, []driver.Value{100.0, int64(5)}), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	width := 100.0
+	buckets, err := builder.Histogram(context.Background(), &testUserSM{SearchModel: &SearchModel{Histogram: &Histogram{Field: "salary", Width: &width}}, Status: []string{"A"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Bucket{{Key: 0.0, Count: 2}, {Key: 100.0, Count: 5}}; !reflect.DeepEqual(buckets, want) {
+		t.Errorf("buckets = %v, want %v", buckets, want)
+	}
+	if q := f.Queries()[0]; !reflect.DeepEqual(q.Args, []interface{}{"A"}) {
+		t.Errorf("histogram query = %q %v", q.Query, q.Args)
+	}
+	if _, err := builder.Histogram(context.Background(), &testUserSM{SearchModel: &SearchModel{Histogram: &Histogram{Field: "unknown", Width: &width}}}); err == nil {
+		t.Error("unknown field must fail")
+	}
+}
+
+type eventSM struct {
+	*SearchModel
+	Created *DateRange `json:"created" gorm:"column:created"`
+}
+type event struct {
+	Id      string    `json:"id" gorm:"column:id;primary_key"`
+	Created time.Time `json:"created" gorm:"column:created"`
+}
+
+func TestDateHistogramMaxBuckets(t *testing.T) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		return rowsOf([]string{"bucket", "total"}, []driver.Value{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), int64(2)}), nil
+	})
+	modelType := reflect.TypeOf(event{})
+	build := func(ctx context.Context, m interface{}) (string, []interface{}, error) {
+		query, params := NewDefaultQueryBuilder("events", modelType, DriverPostgres).BuildQuery(m)
+		return query, params, nil
+	}
+	first := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	sm := &eventSM{SearchModel: &SearchModel{}, Created: &DateRange{StartDate: &first, EndDate: &last}}
+	_, err := BuildHistogram(context.Background(), db, sm, Histogram{Field: "created", Interval: IntervalDay}, modelType, build, DriverPostgres, 0)
+	if p, ok := err.(*Problem); !ok || p.Status != http.StatusBadRequest {
+		t.Fatalf("the range of more than MaxBucketsDefault days must be 400, got %v", err)
+	}
+	_, err = BuildHistogram(context.Background(), db, sm, Histogram{Field: "created"}, modelType, build, DriverPostgres, 0)
+	if p, ok := err.(*Problem); !ok || p.Status != http.StatusBadRequest {
+		t.Fatalf("empty interval must be 400, got %v", err)
+	}
+	if n := len(f.Queries()); n != 1 {
+		t.Errorf("the invalid interval must be rejected before the query, queries = %d", n)
+	}
+	buckets, err := BuildHistogram(context.Background(), db, sm, Histogram{Field: "created", Interval: IntervalYear}, modelType, build, DriverPostgres, 0)
+	if p, ok := err.(*Problem); !ok || p.Status != http.StatusBadRequest {
+		t.Errorf("9999 years must be 400, got %d buckets, %v", len(buckets), err)
+	}
+	last = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	first = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	buckets, err = BuildHistogram(context.Background(), db, sm, Histogram{Field: "created", Interval: IntervalMonth}, modelType, build, DriverPostgres, 3)
+	if want := []Bucket{{Key: "2024-01-01", Count: 2}, {Key: "2024-02-01"}, {Key: "2024-03-01"}}; err != nil || !reflect.DeepEqual(buckets, want) {
+		t.Errorf("buckets = %v, %v, want %v", buckets, err, want)
+	}
+}
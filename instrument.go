@@ -0,0 +1,59 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	StageDecode = "decode"
+	StageSearch = "search"
+	StageQuery  = "query"
+	StageCount  = "count"
+	StageMap    = "map"
+	StageEncode = "encode"
+)
+
+// Instrument receives the start and the end of each stage of search, for metrics or tracing.
+// The context returned by Start is passed to End, so that a tracer can keep its span in the context.
+type Instrument interface {
+	Start(ctx context.Context, stage string, resource string, action string) context.Context
+	End(ctx context.Context, stage string, resource string, action string, duration time.Duration, rows int64, err error)
+}
+
+type instrumentKey struct{}
+type actionKey struct{}
+
+func WithInstrument(ctx context.Context, instrument Instrument) context.Context {
+	return context.WithValue(ctx, instrumentKey{}, instrument)
+}
+func GetInstrument(ctx context.Context) Instrument {
+	if instrument, ok := ctx.Value(instrumentKey{}).(Instrument); ok {
+		return instrument
+	}
+	return nil
+}
+func WithAction(ctx context.Context, action string) context.Context {
+	return context.WithValue(ctx, actionKey{}, action)
+}
+func GetAction(ctx context.Context) string {
+	if action, ok := ctx.Value(actionKey{}).(string); ok {
+		return action
+	}
+	return ""
+}
+
+// StartSpan starts the stage with the instrument, resource and action of the context; the returned func ends it
+func StartSpan(ctx context.Context, stage string) (context.Context, func(rows int64, err error)) {
+	instrument := GetInstrument(ctx)
+	if instrument == nil {
+		return ctx, func(int64, error) {}
+	}
+	resource := GetResource(ctx)
+	action := GetAction(ctx)
+	start := time.Now()
+	ctx2 := instrument.Start(ctx, stage, resource, action)
+	return ctx2, func(rows int64, err error) {
+		instrument.End(ctx2, stage, resource, action, time.Since(start), rows, err)
+	}
+}
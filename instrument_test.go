@@ -0,0 +1,143 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedStage struct {
+	Stage    string
+	Resource string
+	Action   string
+	Rows     int64
+	Err      bool
+}
+type recordingInstrument struct {
+	mu     sync.Mutex
+	stages []recordedStage
+}
+
+func (r *recordingInstrument) Start(ctx context.Context, stage string, resource string, action string) context.Context {
+	return ctx
+}
+func (r *recordingInstrument) End(ctx context.Context, stage string, resource string, action string, duration time.Duration, rows int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stages = append(r.stages, recordedStage{Stage: stage, Resource: resource, Action: action, Rows: rows, Err: err != nil})
+}
+func (r *recordingInstrument) Stages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stages := make([]string, 0)
+	for _, s := range r.stages {
+		stages = append(stages, s.Stage)
+	}
+	return stages
+}
+
+func TestInstrumentStages(t *testing.T) {
+	builder, _ := newUserDB(t, 25)
+	builder.Map = func(ctx context.Context, model interface{}) (interface{}, error) {
+		return model, nil
+	}
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	instrument := &recordingInstrument{}
+	h.Instrument = instrument
+	h.Search(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users?limit=10", nil))
+	if want := []string{StageDecode, StageQuery, StageCount, StageMap, StageSearch, StageEncode}; !reflect.DeepEqual(instrument.Stages(), want) {
+		t.Errorf("stages = %v, want %v", instrument.Stages(), want)
+	}
+	for _, s := range instrument.stages {
+		if s.Resource != "test-user" || s.Action != Search {
+			t.Errorf("stage = %+v", s)
+		}
+		if s.Stage == StageQuery && s.Rows != 2 {
+			t.Errorf("query rows = %d", s.Rows)
+		}
+	}
+}
+
+func TestPrometheusExport(t *testing.T) {
+	p := NewPrometheusInstrument("app")
+	p.End(context.Background(), StageQuery, "user", Search, 20*time.Millisecond, 5, nil)
+	p.End(context.Background(), StageQuery, "user", Search, 2*time.Second, 0, context.DeadlineExceeded)
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	l := `stage="query",resource="user",action="search"`
+	for _, want := range []string{
+		`app_stage_duration_seconds_bucket{` + l + `,le="0.025"} 1`,
+		`app_stage_duration_seconds_bucket{` + l + `,le="2.5"} 2`,
+		`app_stage_duration_seconds_bucket{` + l + `,le="+Inf"} 2`,
+		`app_stage_duration_seconds_count{` + l + `} 2`,
+		`app_stage_rows_total{` + l + `} 5`,
+		`app_stage_errors_total{` + l + `} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPrometheusKeepsBucketsOfMetrics(t *testing.T) {
+	p := NewPrometheusInstrument()
+	p.End(context.Background(), StageQuery, "user", Search, 20*time.Millisecond, 1, nil)
+	p.Buckets = []float64{1}
+	p.End(context.Background(), StageQuery, "user", Search, 20*time.Millisecond, 1, nil)
+	p.End(context.Background(), StageCount, "user", Search, 20*time.Millisecond, 1, nil)
+	body := p.Export()
+	for _, want := range []string{
+		`search_stage_duration_seconds_bucket{stage="query",resource="user",action="search",le="0.025"} 2`,
+		`search_stage_duration_seconds_bucket{stage="count",resource="user",action="search",le="1"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+type spanKey struct{}
+type spanInstrument struct {
+	recordingInstrument
+}
+
+func (s *spanInstrument) Start(ctx context.Context, stage string, resource string, action string) context.Context {
+	return context.WithValue(ctx, spanKey{}, stage)
+}
+
+func TestInstrumentOfBuilder(t *testing.T) {
+	var spans []string
+	db, _ := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		span, _ := ctx.Value(spanKey{}).(string)
+		spans = append(spans, span)
+		if isCountQuery(query) {
+			return totalOf(25), nil
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	instrument := &spanInstrument{}
+	builder.Instrument = instrument
+	builder.Resource = "user"
+	if _, _, err := builder.Search(context.Background(), &testUserSM{SearchModel: &SearchModel{Limit: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{StageQuery, StageCount}; !reflect.DeepEqual(spans, want) {
+		t.Errorf("the queries must have the context of their spans: %v, want %v", spans, want)
+	}
+	if len(instrument.stages) != 2 {
+		t.Errorf("stages = %+v", instrument.stages)
+	}
+	for _, s := range instrument.stages {
+		if s.Resource != "user" {
+			t.Errorf("the stage must have the resource of the builder: %+v", s)
+		}
+	}
+}
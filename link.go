@@ -0,0 +1,109 @@
+package search
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderTotalCount = "X-Total-Count"
+	HeaderLink       = "Link"
+	ParamPage        = "page"
+	ParamLimit       = "limit"
+)
+
+func GetLastPage(count int64, pageSize int64, firstPageSize int64) int64 {
+	if pageSize <= 0 {
+		return 1
+	}
+	if firstPageSize > 0 {
+		if count <= firstPageSize {
+			return 1
+		}
+		return 1 + (count-firstPageSize+pageSize-1)/pageSize
+	}
+	if count <= 0 {
+		return 1
+	}
+	return (count + pageSize - 1) / pageSize
+}
+func BuildPageUrl(r *http.Request, page int64, pageSize int64) string {
+	u := *r.URL
+	if len(u.Host) == 0 && len(r.Host) > 0 {
+		u.Host = r.Host
+		if r.TLS != nil {
+			u.Scheme = "https"
+		} else {
+			u.Scheme = "http"
+		}
+	}
+	ps := u.Query()
+	ps.Set(ParamPage, strconv.FormatInt(page, 10))
+	ps.Set(ParamLimit, strconv.FormatInt(pageSize, 10))
+	u.RawQuery = ps.Encode()
+	return u.String()
+}
+
+// BuildLinks builds the RFC 8288 Link header value with first, prev, next and last relations
+func BuildLinks(r *http.Request, count int64, pageIndex int64, pageSize int64, firstPageSize int64) string {
+	if pageSize <= 0 {
+		return ""
+	}
+	if pageIndex < 1 {
+		pageIndex = 1
+	}
+	lastPage := GetLastPage(count, pageSize, firstPageSize)
+	links := make([]string, 0)
+	links = append(links, buildLink(r, 1, pageSize, "first"))
+	if pageIndex > 1 {
+		prev := pageIndex - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		links = append(links, buildLink(r, prev, pageSize, "prev"))
+	}
+	if pageIndex < lastPage {
+		links = append(links, buildLink(r, pageIndex+1, pageSize, "next"))
+	}
+	links = append(links, buildLink(r, lastPage, pageSize, "last"))
+	return strings.Join(links, ", ")
+}
+
+// BuildNextLinks builds the Link header value without last relation, when the total is unknown
+func BuildNextLinks(r *http.Request, hasNext bool, pageIndex int64, pageSize int64) string {
+	if pageSize <= 0 {
+		return ""
+	}
+	if pageIndex < 1 {
+		pageIndex = 1
+	}
+	links := make([]string, 0)
+	links = append(links, buildLink(r, 1, pageSize, "first"))
+	if pageIndex > 1 {
+		links = append(links, buildLink(r, pageIndex-1, pageSize, "prev"))
+	}
+	if hasNext {
+		links = append(links, buildLink(r, pageIndex+1, pageSize, "next"))
+	}
+	return strings.Join(links, ", ")
+}
+func buildLink(r *http.Request, page int64, pageSize int64, rel string) string {
+	return "<" + BuildPageUrl(r, page, pageSize) + `>; rel="` + rel + `"`
+}
+func SetPagingHeaders(w http.ResponseWriter, r *http.Request, count int64, pageIndex int64, pageSize int64, firstPageSize int64) {
+	h := w.Header()
+	h.Set(HeaderTotalCount, strconv.FormatInt(count, 10))
+	links := BuildLinks(r, count, pageIndex, pageSize, firstPageSize)
+	if len(links) > 0 {
+		h.Set(HeaderLink, links)
+	}
+	h.Add("Access-Control-Expose-Headers", HeaderTotalCount+", "+HeaderLink)
+}
+func SetNextPagingHeaders(w http.ResponseWriter, r *http.Request, hasNext bool, pageIndex int64, pageSize int64) {
+	links := BuildNextLinks(r, hasNext, pageIndex, pageSize)
+	if len(links) > 0 {
+		w.Header().Set(HeaderLink, links)
+	}
+	w.Header().Add("Access-Control-Expose-Headers", HeaderLink)
+}
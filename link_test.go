@@ -0,0 +1,118 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newUserDB(t testing.TB, total int64) (*SearchBuilder, *fakeDB) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			return totalOf(total), nil
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john"), testUserRow("2", "joe")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	return builder, f
+}
+
+func TestGetLastPage(t *testing.T) {
+	cases := []struct {
+		count, pageSize, firstPageSize, last int64
+	}{
+		{0, 10, 0, 1},
+		{10, 10, 0, 1},
+		{11, 10, 0, 2},
+		{25, 10, 0, 3},
+		{5, 10, 5, 1},
+		{6, 10, 5, 2},
+		{26, 10, 5, 4},
+		{100, 0, 0, 1},
+	}
+	for _, c := range cases {
+		if last := GetLastPage(c.count, c.pageSize, c.firstPageSize); last != c.last {
+			t.Errorf("GetLastPage(%d, %d, %d) = %d, want %d", c.count, c.pageSize, c.firstPageSize, last, c.last)
+		}
+	}
+}
+
+func TestBuildLinks(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://api.test/users?status=A&page=2&limit=10", nil)
+	links := BuildLinks(r, 25, 2, 10, 0)
+	for _, want := range []string{
+		`<http://api.test/users?limit=10&page=1&status=A>; rel="first"`,
+		`<http://api.test/users?limit=10&page=1&status=A>; rel="prev"`,
+		`<http://api.test/users?limit=10&page=3&status=A>; rel="next"`,
+		`<http://api.test/users?limit=10&page=3&status=A>; rel="last"`,
+	} {
+		if !strings.Contains(links, want) {
+			t.Errorf("links %q do not contain %q", links, want)
+		}
+	}
+	if links := BuildLinks(r, 25, 3, 10, 0); strings.Contains(links, `rel="next"`) {
+		t.Errorf("last page must not have next link: %q", links)
+	}
+	if links := BuildNextLinks(r, false, 1, 10); strings.Contains(links, `rel="next"`) || strings.Contains(links, `rel="last"`) {
+		t.Errorf("next links without next page: %q", links)
+	}
+}
+
+func TestHeaderPaging(t *testing.T) {
+	builder, _ := newUserDB(t, 25)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	h.HeaderPaging = true
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "http://api.test/users?page=2&limit=10", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if total := w.Header().Get(HeaderTotalCount); total != "25" {
+		t.Errorf("X-Total-Count = %q, want 25", total)
+	}
+	if links := w.Header().Get(HeaderLink); !strings.Contains(links, `page=3>; rel="last"`) {
+		t.Errorf("Link = %q", links)
+	}
+	var users []testUser
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+		t.Fatalf("body must be the results array: %v %s", err, w.Body.String())
+	}
+	if len(users) != 2 || users[0].Username != "john" {
+		t.Errorf("users = %+v", users)
+	}
+}
+
+func TestHeaderPagingPost(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	h.HeaderPaging = true
+	body := `{"status":["A"],"limit":10}`
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodPost, "http://api.test/users/search", strings.NewReader(body)))
+	next := ""
+	for _, link := range strings.Split(w.Header().Get(HeaderLink), ", ") {
+		if strings.HasSuffix(link, `rel="next"`) {
+			next = link[1:strings.Index(link, ">")]
+		}
+	}
+	if next != "http://api.test/users/search?limit=10&page=2" {
+		t.Fatalf("next = %q, Link = %q", next, w.Header().Get(HeaderLink))
+	}
+	w = httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodPost, next, strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	queries := f.Queries()
+	if q := queries[len(queries)-2]; !strings.HasSuffix(q.Query, "where status in (?) limit 10 offset 10 ") {
+		t.Errorf("query of the next link = %q", q.Query)
+	}
+	if links := w.Header().Get(HeaderLink); !strings.Contains(links, `page=1>; rel="prev"`) || !strings.Contains(links, `page=3>; rel="next"`) {
+		t.Errorf("Link of page 2 = %q", links)
+	}
+}
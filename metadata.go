@@ -0,0 +1,166 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// FieldMetadata keeps the tags of the struct field, parsed once
+type FieldMetadata struct {
+	Index int
+	Name  string
+	// the json name, the first part of json tag
+	Json     string
+	HasJson  bool
+	JsonTags []string
+	// the gorm column; HasGorm is false if there is no gorm tag
+	Column    string
+	HasColumn bool
+	HasGorm   bool
+	// the column of sql_builder tag, which overrides the gorm column in BuildQuery
+	SqlBuilderColumn *string
+	Match            string
+	HasMatch         bool
+	Keyword          string
+	HasKeyword       bool
+}
+
+// Metadata keeps the fields, the columns and the SearchModel index of the struct type, embedded by pointer or by value; it must not be modified
+type Metadata struct {
+	Type             reflect.Type
+	Fields           []FieldMetadata
+	SearchModelIndex int
+	Columns          []string
+	byJson           map[string]int
+	byJsonTag        map[string]int
+	byName           map[string]int
+	columnIndexes    map[string]int
+	oracleIndexes    map[string]int
+}
+
+var metadataCache sync.Map
+
+// GetMetadata returns the metadata of the struct type, built once per type
+func GetMetadata(modelType reflect.Type) *Metadata {
+	if m, ok := metadataCache.Load(modelType); ok {
+		return m.(*Metadata)
+	}
+	m, _ := metadataCache.LoadOrStore(modelType, BuildMetadata(modelType))
+	return m.(*Metadata)
+}
+func BuildMetadata(modelType reflect.Type) *Metadata {
+	numField := modelType.NumField()
+	m := &Metadata{
+		Type:             modelType,
+		Fields:           make([]FieldMetadata, numField),
+		SearchModelIndex: -1,
+		byJson:           make(map[string]int),
+		byJsonTag:        make(map[string]int),
+		byName:           make(map[string]int),
+		columnIndexes:    make(map[string]int),
+		oracleIndexes:    make(map[string]int),
+	}
+	columns := make([]string, 0)
+	searchModelType := reflect.TypeOf(SearchModel{})
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		f := FieldMetadata{Index: i, Name: field.Name}
+		if (field.Type == searchModelType || field.Type == reflect.PtrTo(searchModelType)) && m.SearchModelIndex < 0 {
+			m.SearchModelIndex = i
+		}
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			f.HasJson = true
+			f.JsonTags = strings.Split(tag, ",")
+			f.Json = f.JsonTags[0]
+			if _, exist := m.byJson[f.Json]; !exist {
+				m.byJson[f.Json] = i
+			}
+			for _, t := range f.JsonTags {
+				t = strings.TrimSpace(t)
+				if _, exist := m.byJsonTag[t]; !exist {
+					m.byJsonTag[t] = i
+				}
+			}
+		}
+		if tag, ok := field.Tag.Lookup("gorm"); ok {
+			f.HasGorm = true
+			f.Column, f.HasColumn = FindTag(tag, "column")
+			if f.HasColumn {
+				columns = append(columns, f.Column)
+				m.columnIndexes[f.Column] = i
+				m.oracleIndexes[strings.ToUpper(f.Column)] = i
+			}
+		}
+		f.SqlBuilderColumn = GetColumnNameFromSqlBuilderTag(field)
+		f.Match, f.HasMatch = field.Tag.Lookup("match")
+		f.Keyword, f.HasKeyword = field.Tag.Lookup("keyword")
+		if _, exist := m.byName[field.Name]; !exist {
+			m.byName[field.Name] = i
+		}
+		m.Fields[i] = f
+	}
+	m.Columns = columns[:len(columns):len(columns)]
+	return m
+}
+
+// searchModelOf returns the SearchModel of the struct, embedded by pointer or by value; the embedded value is copied if it is not addressable
+func searchModelOf(value reflect.Value) *SearchModel {
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	i := GetMetadata(value.Type()).SearchModelIndex
+	if i < 0 {
+		return nil
+	}
+	field := value.Field(i)
+	if field.Kind() == reflect.Ptr {
+		sm, _ := field.Interface().(*SearchModel)
+		return sm
+	}
+	if field.CanAddr() {
+		return field.Addr().Interface().(*SearchModel)
+	}
+	sm := field.Interface().(SearchModel)
+	return &sm
+}
+
+// FieldByJson returns the first field, of which the json name is jsonName
+func (m *Metadata) FieldByJson(jsonName string) (*FieldMetadata, bool) {
+	if i, ok := m.byJson[jsonName]; ok {
+		return &m.Fields[i], true
+	}
+	return nil, false
+}
+
+// FieldByJsonTag returns the first field, which has jsonName in any part of json tag
+func (m *Metadata) FieldByJsonTag(jsonName string) (*FieldMetadata, bool) {
+	if i, ok := m.byJsonTag[jsonName]; ok {
+		return &m.Fields[i], true
+	}
+	return nil, false
+}
+func (m *Metadata) FieldByName(name string) (*FieldMetadata, bool) {
+	if i, ok := m.byName[name]; ok {
+		return &m.Fields[i], true
+	}
+	return nil, false
+}
+
+// ColumnIndexes returns the copy of the field indexes by column, with upper case columns for oracle
+func (m *Metadata) ColumnIndexes(driver string) map[string]int {
+	indexes := m.getColumnIndexes(driver)
+	c := make(map[string]int, len(indexes))
+	for column, i := range indexes {
+		c[column] = i
+	}
+	return c
+}
+
+// getColumnIndexes returns the shared field indexes by column, which must not be modified
+func (m *Metadata) getColumnIndexes(driver string) map[string]int {
+	if driver == DriverOracle {
+		return m.oracleIndexes
+	}
+	return m.columnIndexes
+}
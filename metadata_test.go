@@ -0,0 +1,134 @@
+package search
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type valueUserSM struct {
+	SearchModel
+	Username string   `json:"username" gorm:"column:username" keyword:"prefix"`
+	Status   []string `json:"status" gorm:"column:status"`
+}
+
+func TestMetadataOfValueEmbed(t *testing.T) {
+	if i := GetMetadata(reflect.TypeOf(valueUserSM{})).SearchModelIndex; i != 0 {
+		t.Errorf("index of SearchModel = %d", i)
+	}
+	if i := GetMetadata(reflect.TypeOf(testUserSM{})).SearchModelIndex; i != 0 {
+		t.Errorf("index of *SearchModel = %d", i)
+	}
+	sm := valueUserSM{SearchModel: SearchModel{Page: 2, Limit: 20, FirstLimit: 10}, Status: []string{"A"}}
+	if page, limit, first, err := ExtractSearch(sm); err != nil || page != 2 || limit != 20 || first != 10 {
+		t.Errorf("page %d, limit %d, first %d, err %v", page, limit, first, err)
+	}
+	if s := GetSearchModel(&sm); s != &sm.SearchModel {
+		t.Error("GetSearchModel must return the embedded search model")
+	}
+	if s := GetSearchModel(sm); s == nil || s.Limit != 20 {
+		t.Errorf("GetSearchModel of the value = %+v", s)
+	}
+
+	modelType := reflect.TypeOf(testUser{})
+	byValue, params := NewDefaultQueryBuilder("users", modelType, DriverPostgres).BuildQuery(&sm)
+	byPtr, _ := NewDefaultQueryBuilder("users", modelType, DriverPostgres).BuildQuery(&testUserSM{SearchModel: &SearchModel{Page: 2, Limit: 20}, Status: []string{"A"}})
+	if byValue != byPtr || !reflect.DeepEqual(params, []interface{}{"A"}) {
+		t.Errorf("value embed = %q %v, pointer embed = %q", byValue, params, byPtr)
+	}
+}
+
+func TestGetColumnsSelectReturnsCopy(t *testing.T) {
+	modelType := reflect.TypeOf(testUser{})
+	columns := GetColumnsSelect(modelType)
+	columns[0] = "password"
+	if c := GetColumnsSelect(modelType); c[0] != "id" {
+		t.Errorf("the cached columns are modified: %v", c)
+	}
+}
+
+func TestGetMetadataConcurrent(t *testing.T) {
+	type concurrentModel struct {
+		Id   string `json:"id" gorm:"column:id"`
+		Name string `json:"name,omitempty" gorm:"column:name" match:"prefix"`
+	}
+	modelType := reflect.TypeOf(concurrentModel{})
+	results := make([]*Metadata, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = GetMetadata(modelType)
+		}(i)
+	}
+	wg.Wait()
+	for _, m := range results {
+		if m != results[0] {
+			t.Fatal("the metadata must be built once per type")
+		}
+	}
+	f, ok := results[0].FieldByJson("name")
+	if !ok || f.Column != "name" || f.Match != "prefix" || !reflect.DeepEqual(f.JsonTags, []string{"name", "omitempty"}) {
+		t.Errorf("field = %+v", f)
+	}
+	if i := results[0].ColumnIndexes(DriverOracle)["NAME"]; i != 1 {
+		t.Errorf("oracle index = %d", i)
+	}
+	indexes, _ := GetColumnIndexes(modelType, DriverPostgres)
+	delete(indexes, "name")
+	if indexes, _ := GetColumnIndexes(modelType, DriverPostgres); indexes["name"] != 1 {
+		t.Errorf("the cached indexes must not be modified by the caller: %v", indexes)
+	}
+}
+
+// BenchmarkBuildMetadata is the reflection of each request without the metadata cache
+func BenchmarkBuildMetadata(b *testing.B) {
+	modelType := reflect.TypeOf(testUser{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BuildMetadata(modelType)
+	}
+}
+
+func BenchmarkGetMetadata(b *testing.B) {
+	modelType := reflect.TypeOf(testUser{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GetMetadata(modelType)
+	}
+}
+
+func BenchmarkGetColumnIndexes(b *testing.B) {
+	modelType := reflect.TypeOf(testUser{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		getColumnIndexes(modelType, DriverPostgres)
+	}
+}
+
+func BenchmarkGetFieldByJson(b *testing.B) {
+	modelType := reflect.TypeOf(testUser{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GetFieldByJson(modelType, "salary")
+	}
+}
+
+func BenchmarkExtractSearch(b *testing.B) {
+	sm := valueUserSM{SearchModel: SearchModel{Page: 2, Limit: 20}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ExtractSearch(sm)
+	}
+}
+
+func BenchmarkBuildQuery(b *testing.B) {
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewDefaultQueryBuilder("users", modelType, DriverPostgres)
+	sm := &testUserSM{SearchModel: &SearchModel{Limit: 20, Sort: "-username", Keyword: "jo"}, Status: []string{"A", "I"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.BuildQuery(sm)
+	}
+}
@@ -0,0 +1,235 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchFunc adapts the search func, such as SearchBuilder.Search, to SearchService
+type SearchFunc func(ctx context.Context, searchModel interface{}) (interface{}, int64, error)
+
+func (f SearchFunc) Search(ctx context.Context, searchModel interface{}) (interface{}, int64, error) {
+	return f(ctx, searchModel)
+}
+
+type Middleware func(next SearchService) SearchService
+
+// Chain wraps the service by the middlewares; the first middleware is the outermost one
+func Chain(service SearchService, middlewares ...Middleware) SearchService {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		service = middlewares[i](service)
+	}
+	return service
+}
+
+// Use wraps the search of the handler by the middlewares, and the Counter of HEAD and Count, with the context marked by WithCountOnly
+func (c *SearchHandler) Use(middlewares ...Middleware) {
+	c.search = Chain(SearchFunc(c.search), middlewares...).Search
+	c.middlewares = append(append(make([]Middleware, 0, len(middlewares)+len(c.middlewares)), middlewares...), c.middlewares...)
+}
+
+type countOnlyKey struct{}
+
+// WithCountOnly marks the search as count only, such as the one of SearchHandler.Counter, which returns no results
+func WithCountOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, countOnlyKey{}, true)
+}
+func IsCountOnly(ctx context.Context) bool {
+	v, ok := ctx.Value(countOnlyKey{}).(bool)
+	return ok && v
+}
+
+// Use wraps the search of the searcher by the middlewares
+func (s *Searcher) Use(middlewares ...Middleware) {
+	s.search = Chain(SearchFunc(s.search), middlewares...).Search
+}
+
+// Timeout cancels the context after the duration, and returns without waiting for the search, which may not check the context.
+// The search writes to its own copy of TotalInfo, which is copied back only if the search returns in time;
+// the search must not modify the search model, because the caller keeps using it after the timeout
+func Timeout(timeout time.Duration) Middleware {
+	return func(next SearchService) SearchService {
+		return SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			info := GetTotalInfo(ctx)
+			var own *TotalInfo
+			if info != nil {
+				c := *info
+				own = &c
+				ctx = WithTotalInfo(ctx, own)
+			}
+			type result struct {
+				models interface{}
+				total  int64
+				err    error
+			}
+			ch := make(chan result, 1)
+			go func() {
+				defer func() {
+					if v := recover(); v != nil {
+						ch <- result{err: RecoverToError(v)}
+					}
+				}()
+				models, total, err := next.Search(ctx, m)
+				ch <- result{models, total, err}
+			}()
+			select {
+			case r := <-ch:
+				if info != nil {
+					*info = *own
+				}
+				return r.models, r.total, r.err
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return nil, 0, NewTimeoutError("search timed out after " + timeout.String())
+				}
+				return nil, 0, ctx.Err()
+			}
+		})
+	}
+}
+
+// Retry retries the search on transient errors, with the delay doubled after each attempt; the option replaces IsTransient
+func Retry(attempts int, delay time.Duration, options ...func(error) bool) Middleware {
+	isTransient := IsTransient
+	if len(options) >= 1 && options[0] != nil {
+		isTransient = options[0]
+	}
+	return func(next SearchService) SearchService {
+		return SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+			wait := delay
+			for i := 1; ; i++ {
+				models, total, err := next.Search(ctx, m)
+				if err == nil || i >= attempts || !isTransient(err) {
+					return models, total, err
+				}
+				select {
+				case <-ctx.Done():
+					return models, total, err
+				case <-time.After(wait):
+				}
+				wait = wait * 2
+			}
+		})
+	}
+}
+
+var transientMessages = []string{"connection reset", "connection refused", "broken pipe", "bad connection", "deadlock", "could not serialize", "serialization failure", "too many connections", "i/o timeout"}
+
+// IsTransient returns true for the errors of lost connections, deadlocks and serialization failures
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	s := strings.ToLower(err.Error())
+	for _, m := range transientMessages {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConcurrencyLimiter limits the concurrent searches of each resource, the one of the context set by SearchHandler
+type ConcurrencyLimiter struct {
+	Max   int
+	Limit map[string]int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func NewConcurrencyLimiter(max int, options ...map[string]int) *ConcurrencyLimiter {
+	var limit map[string]int
+	if len(options) >= 1 {
+		limit = options[0]
+	}
+	return &ConcurrencyLimiter{Max: max, Limit: limit, slots: make(map[string]chan struct{})}
+}
+func (l *ConcurrencyLimiter) get(resource string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.slots[resource]
+	if !ok {
+		max := l.Max
+		if n, ok := l.Limit[resource]; ok {
+			max = n
+		}
+		if max <= 0 {
+			return nil
+		}
+		slots = make(chan struct{}, max)
+		l.slots[resource] = slots
+	}
+	return slots
+}
+
+// Middleware waits for a free slot of the resource, or the end of the context
+func (l *ConcurrencyLimiter) Middleware(next SearchService) SearchService {
+	return SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		slots := l.get(GetResource(ctx))
+		if slots == nil {
+			return next.Search(ctx, m)
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+		defer func() { <-slots }()
+		return next.Search(ctx, m)
+	})
+}
+
+// SlowLog logs the searches slower than the threshold; if buildQuery, such as SearchBuilder.Build, is set, the sql and the number of parameters are logged too.
+// The parameters, such as the keyword, may have personal data, so they are not logged.
+func SlowLog(threshold time.Duration, logError func(context.Context, string), options ...func(context.Context, interface{}) (string, []interface{}, error)) Middleware {
+	var buildQuery func(context.Context, interface{}) (string, []interface{}, error)
+	if len(options) >= 1 {
+		buildQuery = options[0]
+	}
+	return func(next SearchService) SearchService {
+		return SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+			start := time.Now()
+			models, total, err := next.Search(ctx, m)
+			duration := time.Since(start)
+			if duration >= threshold && logError != nil {
+				msg := fmt.Sprintf("slow search of %s took %s", GetResource(ctx), duration)
+				if buildQuery != nil {
+					if sql, params, er2 := buildQuery(ctx, m); er2 == nil {
+						msg = msg + fmt.Sprintf(": %s (%d params)", sql, len(params))
+					}
+				}
+				logError(ctx, msg)
+			}
+			return models, total, err
+		})
+	}
+}
+
+// Recover returns the panic of the search, such as the one of BuildQuery, as error
+func Recover(next SearchService) SearchService {
+	return SearchFunc(func(ctx context.Context, m interface{}) (models interface{}, total int64, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				models, total, err = nil, 0, RecoverToError(v)
+			}
+		}()
+		return next.Search(ctx, m)
+	})
+}
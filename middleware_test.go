@@ -0,0 +1,117 @@
+package search
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutKeepsTotalInfo(t *testing.T) {
+	done := make(chan struct{})
+	slow := SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+		if info := GetTotalInfo(ctx); info != nil {
+			info.Exact = false
+			info.HasNext = true
+		}
+		return &[]testUser{}, -1, nil
+	})
+	info := &TotalInfo{Exact: true}
+	ctx := WithTotalInfo(context.Background(), info)
+	_, _, err := Timeout(10*time.Millisecond)(slow).Search(ctx, &testUserSM{SearchModel: &SearchModel{}})
+	if p := ToProblem(err); p.Status != http.StatusGatewayTimeout {
+		t.Fatalf("err = %v", err)
+	}
+	<-done
+	if !info.Exact || info.HasNext {
+		t.Errorf("the abandoned search must not write the TotalInfo of the caller: %+v", info)
+	}
+
+	fast := SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		GetTotalInfo(ctx).Exact = false
+		return &[]testUser{}, -1, nil
+	})
+	info = &TotalInfo{Exact: true}
+	if _, total, err := Timeout(time.Second)(fast).Search(WithTotalInfo(context.Background(), info), nil); err != nil || total != -1 {
+		t.Fatal(total, err)
+	}
+	if info.Exact {
+		t.Error("the TotalInfo of the search must be copied back when it returns in time")
+	}
+}
+
+func TestUseWrapsCounter(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	h := NewSearchHandlerWithBuilder(builder, reflect.TypeOf(testUserSM{}), nil, nil)
+	calls := make([]string, 0)
+	record := func(name string) Middleware {
+		return func(next SearchService) SearchService {
+			return SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+				if IsCountOnly(ctx) {
+					calls = append(calls, name+":count")
+				} else {
+					calls = append(calls, name)
+				}
+				return next.Search(ctx, m)
+			})
+		}
+	}
+	h.Use(record("a"), record("b"))
+	h.Use(record("c"))
+
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	h.Search(w, httptest.NewRequest(http.MethodHead, "/users", nil))
+	h.Count(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/count", nil))
+	want := []string{"c", "a", "b", "c:count", "a:count", "b:count", "c:count", "a:count", "b:count"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if w.Header().Get(HeaderTotalCount) != "25" {
+		t.Errorf("X-Total-Count = %q", w.Header().Get(HeaderTotalCount))
+	}
+	if n := len(f.Queries()); n != 4 {
+		t.Errorf("queries = %d, want 4", n)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	attempts := 0
+	flaky := SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, 0, driver.ErrBadConn
+		}
+		return &[]testUser{}, 2, nil
+	})
+	if _, total, err := Retry(3, time.Millisecond)(flaky).Search(context.Background(), nil); err != nil || total != 2 || attempts != 3 {
+		t.Errorf("total %d, err %v, attempts %d", total, err, attempts)
+	}
+	attempts = 0
+	failing := SearchFunc(func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		attempts++
+		return nil, 0, errors.New("syntax error")
+	})
+	if _, _, err := Retry(3, time.Millisecond)(failing).Search(context.Background(), nil); err == nil || attempts != 1 {
+		t.Errorf("the permanent error must not be retried: %v, attempts %d", err, attempts)
+	}
+}
+
+func TestSlowLogWithoutParams(t *testing.T) {
+	builder, _ := newUserDB(t, 1)
+	var msg string
+	slow := SlowLog(0, func(ctx context.Context, s string) { msg = s }, builder.Build)(SearchFunc(builder.Search))
+	if _, _, err := slow.Search(WithResource(context.Background(), "user"), &testUserSM{SearchModel: &SearchModel{Limit: 10}, Status: []string{"secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg, "slow search of user took ") || !strings.HasSuffix(msg, "where status in (?) (1 params)") || strings.Contains(msg, "secret") {
+		t.Errorf("msg = %q", msg)
+	}
+}
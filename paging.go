@@ -0,0 +1,76 @@
+package search
+
+import (
+	"strconv"
+)
+
+type PagingConfig struct {
+	DefaultPageSize int64 `mapstructure:"default_page_size" json:"defaultPageSize,omitempty" gorm:"column:defaultpagesize" bson:"defaultPageSize,omitempty" dynamodbav:"defaultPageSize,omitempty" firestore:"defaultPageSize,omitempty"`
+	MaxPageSize     int64 `mapstructure:"max_page_size" json:"maxPageSize,omitempty" gorm:"column:maxpagesize" bson:"maxPageSize,omitempty" dynamodbav:"maxPageSize,omitempty" firestore:"maxPageSize,omitempty"`
+	// if true, a limit above MaxPageSize is rejected as 400; else it is clamped to MaxPageSize
+	Reject bool `mapstructure:"reject" json:"reject,omitempty" gorm:"column:reject" bson:"reject,omitempty" dynamodbav:"reject,omitempty" firestore:"reject,omitempty"`
+	// the max number of rows to skip, to guard deep paging; 0 means no limit
+	MaxOffset int64 `mapstructure:"max_offset" json:"maxOffset,omitempty" gorm:"column:maxoffset" bson:"maxOffset,omitempty" dynamodbav:"maxOffset,omitempty" firestore:"maxOffset,omitempty"`
+	// if true, limit 0 means all rows; else limit 0 is set to DefaultPageSize
+	AllowUnlimited bool `mapstructure:"allow_unlimited" json:"allowUnlimited,omitempty" gorm:"column:allowunlimited" bson:"allowUnlimited,omitempty" dynamodbav:"allowUnlimited,omitempty" firestore:"allowUnlimited,omitempty"`
+}
+
+func NewPagingConfig(defaultPageSize int64, maxPageSize int64, options ...bool) PagingConfig {
+	c := PagingConfig{DefaultPageSize: defaultPageSize, MaxPageSize: maxPageSize}
+	if len(options) >= 1 {
+		c.Reject = options[0]
+	}
+	if len(options) >= 2 {
+		c.AllowUnlimited = options[1]
+	}
+	return c
+}
+
+// ApplyPaging corrects page and limit of the search model by the config, or returns the bad request error
+func ApplyPaging(sm *SearchModel, c PagingConfig) error {
+	defaultPageSize := c.DefaultPageSize
+	if defaultPageSize <= 0 {
+		defaultPageSize = PageSizeDefault
+	}
+	if sm.Page < 1 {
+		sm.Page = 1
+	}
+	if sm.Limit < 0 {
+		return NewBadRequestError("invalid limit", ErrorMessage{Field: "limit", Code: RuleMin, Param: "0", Message: "limit must be greater than or equal to 0"})
+	}
+	if sm.Limit == 0 && !c.AllowUnlimited {
+		sm.Limit = defaultPageSize
+	}
+	if c.MaxPageSize > 0 {
+		if sm.Limit > c.MaxPageSize {
+			if c.Reject {
+				p := strconv.FormatInt(c.MaxPageSize, 10)
+				return NewBadRequestError("invalid limit", ErrorMessage{Field: "limit", Code: RuleMax, Param: p, Message: "limit must be less than or equal to " + p})
+			}
+			sm.Limit = c.MaxPageSize
+		}
+		if sm.FirstLimit > c.MaxPageSize {
+			if c.Reject {
+				p := strconv.FormatInt(c.MaxPageSize, 10)
+				return NewBadRequestError("invalid firstLimit", ErrorMessage{Field: "firstLimit", Code: RuleMax, Param: p, Message: "firstLimit must be less than or equal to " + p})
+			}
+			sm.FirstLimit = c.MaxPageSize
+		}
+	}
+	if c.MaxOffset > 0 && sm.Limit > 0 {
+		if offset := GetOffset(sm.Page, sm.Limit, sm.FirstLimit); offset > c.MaxOffset {
+			p := strconv.FormatInt(c.MaxOffset, 10)
+			return NewBadRequestError("page is too deep", ErrorMessage{Field: "page", Code: RuleMax, Param: p, Message: "cannot skip more than " + p + " rows"})
+		}
+	}
+	return nil
+}
+func GetOffset(pageIndex int64, pageSize int64, firstPageSize int64) int64 {
+	if firstPageSize > 0 {
+		if pageIndex <= 1 {
+			return 0
+		}
+		return pageSize*(pageIndex-2) + firstPageSize
+	}
+	return pageSize * (pageIndex - 1)
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestApplyPaging(t *testing.T) {
+	sm := &SearchModel{Limit: 500}
+	if err := ApplyPaging(sm, NewPagingConfig(20, 100)); err != nil || sm.Limit != 100 || sm.Page != 1 {
+		t.Errorf("clamp: %v %+v", err, sm)
+	}
+	sm = &SearchModel{}
+	if err := ApplyPaging(sm, NewPagingConfig(20, 100)); err != nil || sm.Limit != 20 {
+		t.Errorf("default: %v %+v", err, sm)
+	}
+	sm = &SearchModel{}
+	if err := ApplyPaging(sm, NewPagingConfig(20, 100, false, true)); err != nil || sm.Limit != 0 {
+		t.Errorf("unlimited: %v %+v", err, sm)
+	}
+	if err := ApplyPaging(&SearchModel{Limit: 500}, NewPagingConfig(20, 100, true)); err == nil {
+		t.Error("reject must fail")
+	}
+	if err := ApplyPaging(&SearchModel{Limit: -1}, NewPagingConfig(20, 100)); err == nil {
+		t.Error("negative limit must fail")
+	}
+	c := NewPagingConfig(20, 100)
+	c.MaxOffset = 1000
+	if err := ApplyPaging(&SearchModel{Page: 11, Limit: 100}, c); err != nil {
+		t.Errorf("offset 1000: %v", err)
+	}
+	if err := ApplyPaging(&SearchModel{Page: 12, Limit: 100}, c); err == nil {
+		t.Error("offset 1100 must fail")
+	}
+}
+
+func TestValidateMaxPageSize(t *testing.T) {
+	if errs := Validate(&testUserSM{SearchModel: &SearchModel{Limit: 100000}}); len(errs) != 1 || errs[0].Field != "limit" || errs[0].Code != RuleMax {
+		t.Errorf("Validate must reject the limit above MaxPageSizeDefault: %v", errs)
+	}
+	if errs := NewValidator(200000)(&testUserSM{SearchModel: &SearchModel{Limit: 100000}}); len(errs) != 0 {
+		t.Errorf("NewValidator(200000): %v", errs)
+	}
+}
+
+func TestPagingWithValidate(t *testing.T) {
+	builder, f := newUserDB(t, 0)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	h.Validate = Validate
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?limit=100000", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("without paging policy, limit=100000 must be rejected: status %d", w.Code)
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("queries = %+v", f.Queries())
+	}
+
+	h.Paging = &PagingConfig{DefaultPageSize: 20, MaxPageSize: 50}
+	w = httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?limit=100000", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("the paging policy clamps the limit before Validate: status %d %s", w.Code, w.Body.String())
+	}
+	if q := f.Queries()[0].Query; q != "select  id,username,email,status,salary from users limit 50 offset 0 " {
+		t.Errorf("query = %q", q)
+	}
+}
@@ -0,0 +1,100 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type resourceKey struct{}
+
+// Predicate is the sql condition with ? placeholders, such as "department_id = ?"
+type Predicate struct {
+	Condition string
+	Params    []interface{}
+}
+
+// Policy returns the row level predicates of the user; no predicate means all rows
+type Policy func(ctx context.Context, userId string) ([]Predicate, error)
+
+type PolicyRegistry struct {
+	UserId   string
+	mu       sync.RWMutex
+	policies map[string][]Policy
+}
+
+func NewPolicyRegistry(options ...string) *PolicyRegistry {
+	userId := UserId
+	if len(options) >= 1 && len(options[0]) > 0 {
+		userId = options[0]
+	}
+	return &PolicyRegistry{UserId: userId, policies: make(map[string][]Policy)}
+}
+
+// Register adds the policy to the resource, which is SearchHandler.Resource
+func (r *PolicyRegistry) Register(resource string, policy Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies[resource] = append(r.policies[resource], policy)
+}
+func (r *PolicyRegistry) Predicates(ctx context.Context, resource string) ([]Predicate, error) {
+	r.mu.RLock()
+	policies := r.policies[resource]
+	r.mu.RUnlock()
+	userId := ""
+	if u, ok := ctx.Value(r.UserId).(string); ok {
+		userId = u
+	}
+	predicates := make([]Predicate, 0)
+	for _, policy := range policies {
+		ps, err := policy(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, ps...)
+	}
+	return predicates, nil
+}
+
+// Apply merges the predicates of the resource to the query
+func (r *PolicyRegistry) Apply(ctx context.Context, resource string, sql string, params []interface{}, driver string) (string, []interface{}, error) {
+	predicates, err := r.Predicates(ctx, resource)
+	if err != nil {
+		return sql, params, err
+	}
+	conditions := make([]string, 0)
+	values := make([]interface{}, 0)
+	for _, p := range predicates {
+		conditions = append(conditions, "("+p.Condition+")")
+		values = append(values, p.Params...)
+	}
+	sql2, params2 := AddConditions(sql, params, conditions, values, driver)
+	return sql2, params2, nil
+}
+
+// CheckPredicates is the test helper, which returns error if the conditions of the user are not the expected ones
+func (r *PolicyRegistry) CheckPredicates(ctx context.Context, resource string, userId string, expected ...string) error {
+	predicates, err := r.Predicates(context.WithValue(ctx, r.UserId, userId), resource)
+	if err != nil {
+		return err
+	}
+	conditions := make([]string, 0)
+	for _, p := range predicates {
+		conditions = append(conditions, p.Condition)
+	}
+	if strings.Join(conditions, "\n") != strings.Join(expected, "\n") {
+		return fmt.Errorf("predicates of %s for user %s: expected %q, got %q", resource, userId, expected, conditions)
+	}
+	return nil
+}
+
+func WithResource(ctx context.Context, resource string) context.Context {
+	return context.WithValue(ctx, resourceKey{}, resource)
+}
+func GetResource(ctx context.Context) string {
+	if resource, ok := ctx.Value(resourceKey{}).(string); ok {
+		return resource
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func newPolicies() *PolicyRegistry {
+	policies := NewPolicyRegistry()
+	policies.Register("account", func(ctx context.Context, userId string) ([]Predicate, error) {
+		if userId == "admin" {
+			return nil, nil
+		}
+		return []Predicate{{Condition: "owner = ?", Params: []interface{}{userId}}}, nil
+	})
+	policies.Register("account", func(ctx context.Context, userId string) ([]Predicate, error) {
+		if userId == "admin" {
+			return nil, nil
+		}
+		return []Predicate{{Condition: "status <> ? or shared = ?", Params: []interface{}{"D", true}}}, nil
+	})
+	return policies
+}
+
+func TestCheckPredicates(t *testing.T) {
+	policies := newPolicies()
+	ctx := context.Background()
+	if err := policies.CheckPredicates(ctx, "account", "u1", "owner = ?", "status <> ? or shared = ?"); err != nil {
+		t.Error(err)
+	}
+	if err := policies.CheckPredicates(ctx, "account", "admin"); err != nil {
+		t.Error(err)
+	}
+	if err := policies.CheckPredicates(ctx, "account", "u1", "owner = ?"); err == nil {
+		t.Error("the missing predicate must be reported")
+	}
+	if err := policies.CheckPredicates(ctx, "other", "u1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPolicyAfterKeyword(t *testing.T) {
+	policies := newPolicies()
+	ctx := context.WithValue(context.Background(), UserId, "u1")
+	sql, params := BuildQuery(newAccountSM(), "accounts", reflect.TypeOf(account{}), DriverPostgres)
+	sql, params, err := policies.Apply(ctx, "account", sql, params, DriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "select  username,email,status,age,created from accounts where (status in ($1,$2) AND age = $3 AND created >= $4 AND created < $5 AND (username ilike $6 OR email ilike $7)) AND (owner = $8) AND (status <> $9 or shared = $10)"
+	if sql != want {
+		t.Errorf("sql = %q, want %q", sql, want)
+	}
+	if len(params) != 10 || params[7] != "u1" || params[8] != "D" || params[9] != true {
+		t.Errorf("params = %v", params)
+	}
+}
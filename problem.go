@@ -0,0 +1,98 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	ProblemContentType  = "application/problem+json"
+	ProblemBadRequest   = "bad-request"
+	ProblemUnknownField = "unknown-field"
+	ProblemInvalidSort  = "invalid-sort"
+	ProblemTimeout      = "timeout"
+	ProblemForbidden    = "forbidden"
+	ProblemInternal     = "internal"
+)
+
+// Problem is the RFC 7807 error response, and also the typed error of search
+type Problem struct {
+	Type   string         `mapstructure:"type" json:"type" gorm:"column:type" bson:"type" dynamodbav:"type" firestore:"type"`
+	Title  string         `mapstructure:"title" json:"title" gorm:"column:title" bson:"title" dynamodbav:"title" firestore:"title"`
+	Status int            `mapstructure:"status" json:"status" gorm:"column:status" bson:"status" dynamodbav:"status" firestore:"status"`
+	Detail string         `mapstructure:"detail" json:"detail,omitempty" gorm:"column:detail" bson:"detail,omitempty" dynamodbav:"detail,omitempty" firestore:"detail,omitempty"`
+	Errors []ErrorMessage `mapstructure:"errors" json:"errors,omitempty" gorm:"column:errors" bson:"errors,omitempty" dynamodbav:"errors,omitempty" firestore:"errors,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if len(p.Detail) > 0 {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+func NewBadRequestError(detail string, errs ...ErrorMessage) *Problem {
+	return &Problem{Type: ProblemBadRequest, Title: "Bad Request", Status: http.StatusBadRequest, Detail: detail, Errors: errs}
+}
+func NewUnknownFieldError(field string) *Problem {
+	return &Problem{Type: ProblemUnknownField, Title: "Unknown Field", Status: http.StatusBadRequest, Detail: "field " + field + " does not exist", Errors: []ErrorMessage{{Field: field, Code: "unknown", Message: "field " + field + " does not exist"}}}
+}
+func NewInvalidSortError(field string) *Problem {
+	return &Problem{Type: ProblemInvalidSort, Title: "Invalid Sort", Status: http.StatusBadRequest, Detail: "cannot sort by " + field, Errors: []ErrorMessage{{Field: "sort", Code: "invalid", Param: field, Message: "cannot sort by " + field}}}
+}
+func NewForbiddenError(detail string) *Problem {
+	return &Problem{Type: ProblemForbidden, Title: "Forbidden", Status: http.StatusForbidden, Detail: detail}
+}
+func NewProblem(status int, problemType string, detail string, errs ...ErrorMessage) *Problem {
+	return &Problem{Type: problemType, Title: http.StatusText(status), Status: status, Detail: detail, Errors: errs}
+}
+func NewTimeoutError(detail string) *Problem {
+	return &Problem{Type: ProblemTimeout, Title: "Timeout", Status: http.StatusGatewayTimeout, Detail: detail}
+}
+func NewInternalError(detail string) *Problem {
+	return &Problem{Type: ProblemInternal, Title: InternalServerError, Status: http.StatusInternalServerError, Detail: detail}
+}
+
+// ToProblem converts the error to problem; the detail of unknown errors is not exposed
+func ToProblem(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewTimeoutError("search did not complete in time")
+	}
+	return NewInternalError("")
+}
+func RecoverToError(v interface{}) error {
+	switch e := v.(type) {
+	case error:
+		return e
+	case string:
+		return errors.New(e)
+	default:
+		return fmt.Errorf("%v", e)
+	}
+}
+
+func respondProblem(w http.ResponseWriter, r *http.Request, err error, logError func(context.Context, string), resource string, action string, writeLog func(ctx context.Context, resource string, action string, success bool, desc string) error) {
+	p := ToProblem(err)
+	if rw, ok := w.(*responseWriter); ok {
+		rw.err = err
+	}
+	if logError != nil && p.Status >= http.StatusInternalServerError {
+		logError(r.Context(), err.Error())
+	}
+	response, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	if r.Method != http.MethodHead {
+		w.Write(response)
+	}
+	if writeLog != nil {
+		writeLog(r.Context(), resource, action, false, err.Error())
+	}
+}
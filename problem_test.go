@@ -0,0 +1,92 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("content type = %q", ct)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if p.Status != w.Code {
+		t.Errorf("problem status %d, response status %d", p.Status, w.Code)
+	}
+	return p
+}
+
+func TestInvalidSortProblem(t *testing.T) {
+	builder, _ := newUserDB(t, 0)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?sort=-password", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d", w.Code)
+	}
+	if p := decodeProblem(t, w); p.Type != ProblemInvalidSort || len(p.Errors) != 1 || p.Errors[0].Param != "password" {
+		t.Errorf("problem = %+v", p)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var logged string
+	search := func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		panic("secret details")
+	}
+	h := NewSearchHandler(search, reflect.TypeOf(testUserSM{}), func(ctx context.Context, s string) { logged = s }, nil)
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d", w.Code)
+	}
+	if p := decodeProblem(t, w); p.Type != ProblemInternal || len(p.Detail) != 0 {
+		t.Errorf("the panic must not be exposed: %+v", p)
+	}
+	if !strings.HasPrefix(logged, "panic in GET /users: secret details\n") || !strings.Contains(logged, "problem_test.go") {
+		t.Errorf("the panic must be logged with the stack: %q", logged)
+	}
+}
+
+func TestInvalidSortError(t *testing.T) {
+	builder, f := newUserDB(t, 0)
+	for _, sm := range []*testUserSM{
+		{SearchModel: &SearchModel{Sort: "username,-password"}},
+		{SearchModel: &SearchModel{Excluding: map[string][]interface{}{"password": {"x"}}}},
+	} {
+		_, _, err := builder.Search(context.Background(), sm)
+		if p, ok := err.(*Problem); !ok || p.Status != http.StatusBadRequest {
+			t.Errorf("%+v: err = %v", sm.SearchModel, err)
+		}
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("invalid search model must not query: %+v", f.Queries())
+	}
+	query, _ := builder.BuildQuery(&testUserSM{SearchModel: &SearchModel{Sort: "-password, -username", Excluding: map[string][]interface{}{"password": {"x"}}}})
+	if query != "select  id,username,email,status,salary from users order by username desc" {
+		t.Errorf("BuildQuery must skip the fields, which do not exist: %q", query)
+	}
+}
+
+func TestToProblem(t *testing.T) {
+	if p := ToProblem(fmt.Errorf("search: %w", context.DeadlineExceeded)); p.Status != http.StatusGatewayTimeout {
+		t.Errorf("deadline = %+v", p)
+	}
+	if p := ToProblem(fmt.Errorf("wrapped: %w", NewForbiddenError("no"))); p.Status != http.StatusForbidden {
+		t.Errorf("forbidden = %+v", p)
+	}
+	if p := ToProblem(errors.New("pq: password authentication failed")); p.Status != http.StatusInternalServerError || len(p.Detail) != 0 {
+		t.Errorf("internal = %+v", p)
+	}
+}
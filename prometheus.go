@@ -0,0 +1,131 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type stageLabels struct {
+	stage    string
+	resource string
+	action   string
+}
+type stageMetrics struct {
+	// the bounds of Buckets, when the metric is created
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+	rows    uint64
+	errors  uint64
+}
+
+// PrometheusInstrument keeps latency histograms, row counts and error counts by stage, resource and action,
+// and exports them in the Prometheus text format by ServeHTTP
+type PrometheusInstrument struct {
+	Namespace string
+	// the bucket bounds of the new metrics; the existing metrics keep their bounds
+	Buckets []float64
+	mu      sync.Mutex
+	metrics map[stageLabels]*stageMetrics
+}
+
+func NewPrometheusInstrument(options ...string) *PrometheusInstrument {
+	namespace := "search"
+	if len(options) >= 1 && len(options[0]) > 0 {
+		namespace = options[0]
+	}
+	return &PrometheusInstrument{Namespace: namespace, Buckets: DefaultBuckets, metrics: make(map[stageLabels]*stageMetrics)}
+}
+func (p *PrometheusInstrument) Start(ctx context.Context, stage string, resource string, action string) context.Context {
+	return ctx
+}
+func (p *PrometheusInstrument) End(ctx context.Context, stage string, resource string, action string, duration time.Duration, rows int64, err error) {
+	k := stageLabels{stage: stage, resource: resource, action: action}
+	seconds := duration.Seconds()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.metrics[k]
+	if !ok {
+		bounds := append([]float64(nil), p.Buckets...)
+		m = &stageMetrics{bounds: bounds, buckets: make([]uint64, len(bounds))}
+		p.metrics[k] = m
+	}
+	for i, b := range m.bounds {
+		if seconds <= b {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sum += seconds
+	if rows > 0 {
+		m.rows += uint64(rows)
+	}
+	if err != nil {
+		m.errors++
+	}
+}
+func (p *PrometheusInstrument) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(p.Export()))
+}
+
+// Export returns the metrics in the Prometheus text format
+func (p *PrometheusInstrument) Export() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]stageLabels, 0, len(p.metrics))
+	for k := range p.metrics {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].resource != keys[j].resource {
+			return keys[i].resource < keys[j].resource
+		}
+		if keys[i].action != keys[j].action {
+			return keys[i].action < keys[j].action
+		}
+		return keys[i].stage < keys[j].stage
+	})
+	var sb strings.Builder
+	duration := p.Namespace + "_stage_duration_seconds"
+	sb.WriteString("# HELP " + duration + " Duration of the search stage.\n# TYPE " + duration + " histogram\n")
+	for _, k := range keys {
+		m := p.metrics[k]
+		l := labels(k)
+		for i, b := range m.bounds {
+			fmt.Fprintf(&sb, "%s_bucket{%s,le=\"%s\"} %d\n", duration, l, strconv.FormatFloat(b, 'f', -1, 64), m.buckets[i])
+		}
+		fmt.Fprintf(&sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", duration, l, m.count)
+		fmt.Fprintf(&sb, "%s_sum{%s} %s\n", duration, l, strconv.FormatFloat(m.sum, 'f', -1, 64))
+		fmt.Fprintf(&sb, "%s_count{%s} %d\n", duration, l, m.count)
+	}
+	rows := p.Namespace + "_stage_rows_total"
+	sb.WriteString("# HELP " + rows + " Rows returned by the search stage.\n# TYPE " + rows + " counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s{%s} %d\n", rows, labels(k), p.metrics[k].rows)
+	}
+	errs := p.Namespace + "_stage_errors_total"
+	sb.WriteString("# HELP " + errs + " Errors of the search stage.\n# TYPE " + errs + " counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s{%s} %d\n", errs, labels(k), p.metrics[k].errors)
+	}
+	return sb.String()
+}
+func labels(k stageLabels) string {
+	return `stage="` + escapeLabel(k.stage) + `",resource="` + escapeLabel(k.resource) + `",action="` + escapeLabel(k.action) + `"`
+}
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
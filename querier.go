@@ -0,0 +1,106 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+)
+
+// Querier is implemented by *sql.DB, *sql.Tx, *sql.Conn and Router
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type driverGetter interface {
+	Driver() driver.Driver
+}
+type driverNamer interface {
+	DriverName() string
+}
+
+// WithDriver tells GetDriver the driver of the querier, such as *sql.Tx or *sql.Conn, which has no Driver()
+func WithDriver(db Querier, driver string) Querier {
+	return &driverQuerier{Querier: db, driver: driver}
+}
+
+type driverQuerier struct {
+	Querier
+	driver string
+}
+
+func (q *driverQuerier) DriverName() string {
+	return q.driver
+}
+
+// unwrapQuerier returns the querier of WithDriver
+func unwrapQuerier(db Querier) Querier {
+	if q, ok := db.(*driverQuerier); ok {
+		return q.Querier
+	}
+	return db
+}
+
+type txKey struct{}
+type primaryKey struct{}
+
+// WithTx runs the searches of the context in the transaction or on the connection
+func WithTx(ctx context.Context, tx Querier) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+func GetTx(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(Querier); ok {
+		return tx
+	}
+	return nil
+}
+
+// GetQuerier returns the transaction of the context, or db
+func GetQuerier(ctx context.Context, db Querier) Querier {
+	if ctx != nil {
+		if tx := GetTx(ctx); tx != nil {
+			return tx
+		}
+	}
+	return db
+}
+
+// WithPrimary sends the searches of the context to the primary, to read your writes
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+func IsPrimary(ctx context.Context) bool {
+	primary, ok := ctx.Value(primaryKey{}).(bool)
+	return ok && primary
+}
+
+// Router sends the searches to the replicas by round robin, or to the primary if there is no replica or the context is WithPrimary
+type Router struct {
+	Primary  *sql.DB
+	Replicas []*sql.DB
+	next     uint32
+}
+
+func NewRouter(primary *sql.DB, replicas ...*sql.DB) *Router {
+	return &Router{Primary: primary, Replicas: replicas}
+}
+func (r *Router) Read(ctx context.Context) *sql.DB {
+	if len(r.Replicas) == 0 || IsPrimary(ctx) {
+		return r.Primary
+	}
+	i := atomic.AddUint32(&r.next, 1)
+	return r.Replicas[int(i)%len(r.Replicas)]
+}
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.Read(ctx).QueryContext(ctx, query, args...)
+}
+func (r *Router) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.Read(ctx).QueryRowContext(ctx, query, args...)
+}
+func (r *Router) Driver() driver.Driver {
+	return r.Primary.Driver()
+}
+func (r *Router) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return r.Read(ctx).BeginTx(ctx, opts)
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetDriverOfTx(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if d := GetDriver(tx); d != DriverNotSupport {
+		t.Errorf("driver of tx = %q", d)
+	}
+	if d := GetDriver(WithDriver(tx, DriverPostgres)); d != DriverPostgres {
+		t.Errorf("driver = %q, want postgres", d)
+	}
+}
+
+func TestSearchInTx(t *testing.T) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			return totalOf(2), nil
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john"), testUserRow("2", "joe")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, DriverPostgres).BuildQuery)
+	builder.Driver = DriverPostgres
+	builder.Tenant = NewTenantConfig("tenant_id", "tenant")
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	builder.Database = tx
+	builder.Snapshot = true
+
+	ctx := context.WithValue(context.Background(), "tenant", "acme")
+	sm := &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}, Status: []string{"A"}}
+	results, total, err := builder.Search(ctx, sm)
+	if err != nil || total != 2 || len(*results.(*[]testUser)) != 2 {
+		t.Fatal(results, total, err)
+	}
+	queries := f.Queries()
+	if len(queries) != 2 {
+		t.Fatalf("queries = %+v", queries)
+	}
+	if want := "select  id,username,email,status,salary from users where (status in ($1)) AND tenant_id = $2 limit 10 offset 0 "; queries[0].Query != want {
+		t.Errorf("query = %q, want %q", queries[0].Query, want)
+	}
+	for _, q := range queries {
+		if !q.InTx || !reflect.DeepEqual(q.Args, []interface{}{"A", "acme"}) {
+			t.Errorf("query = %+v", q)
+		}
+	}
+	if len(f.begins) != 1 {
+		t.Errorf("the search in the tx must not begin another one: %d", len(f.begins))
+	}
+}
+
+func TestOracleCounting(t *testing.T) {
+	columns := make([]string, len(testUserColumns))
+	for i, c := range testUserColumns {
+		columns[i] = strings.ToUpper(c)
+	}
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			return totalOf(11), nil
+		}
+		return rowsOf(columns, testUserRow("1", "john")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, DriverOracle).BuildQuery)
+	builder.Driver = DriverOracle
+	builder.Counting = NewCappedCount(10)
+	info := &TotalInfo{}
+	results, total, err := builder.Search(WithTotalInfo(context.Background(), info), &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}})
+	if err != nil || total != 10 || info.Exact || info.Strategy != CountCapped || len(*results.(*[]testUser)) != 1 {
+		t.Fatalf("results %v, total %d, err %v, info %+v", results, total, err, info)
+	}
+	queries := f.Queries()
+	if want := "select  id,username,email,status,salary from users offset 0 rows fetch next 10 rows only "; queries[0].Query != want {
+		t.Errorf("query = %q, want %q", queries[0].Query, want)
+	}
+	if want := "select count(*) as total from (select 1 as x from users fetch first 11 rows only) capped"; queries[1].Query != want {
+		t.Errorf("count = %q, want %q", queries[1].Query, want)
+	}
+}
@@ -1,7 +1,9 @@
 package search
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -13,6 +15,7 @@ type QueryBuilder struct {
 	TableName  string
 	ModelType  reflect.Type
 	DriverName string
+	Tenant     *TenantConfig
 }
 
 func NewQueryBuilder(db *sql.DB, tableName string, modelType reflect.Type) *QueryBuilder {
@@ -44,12 +47,51 @@ func GetColumnNameFromSqlBuilderTag(typeOfField reflect.StructField) *string {
 	}
 	return nil
 }
+func NewTenantQueryBuilder(tableName string, modelType reflect.Type, driverName string, tenant *TenantConfig) *QueryBuilder {
+	return &QueryBuilder{TableName: tableName, ModelType: modelType, DriverName: driverName, Tenant: tenant}
+}
 func (b *QueryBuilder) BuildQuery(sm interface{}) (string, []interface{}) {
 	return BuildQuery(sm, b.TableName, b.ModelType, b.DriverName)
 }
+
+// BuildQueryWithContext routes the table and adds the tenant condition by the tenant of the context
+func (b *QueryBuilder) BuildQueryWithContext(ctx context.Context, sm interface{}) (string, []interface{}, error) {
+	if err := CheckSearchModel(sm, b.ModelType); err != nil {
+		return "", nil, err
+	}
+	if b.Tenant == nil {
+		sql, params := buildQuery(sm, b.TableName, b.ModelType, b.DriverName, GetHiddenFields(ctx))
+		return sql, params, nil
+	}
+	tableName := b.TableName
+	if b.Tenant.Mode == TenantSchema || b.Tenant.Mode == TenantTable {
+		if b.Tenant.Resolve == nil {
+			return "", nil, errors.New("tenant resolver is required")
+		}
+		tenant, err := b.Tenant.Resolve(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		tableName, err = GetTenantTable(b.TableName, tenant, b.Tenant.Mode)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	sql, params := buildQuery(sm, tableName, b.ModelType, b.DriverName, GetHiddenFields(ctx))
+	return b.Tenant.Apply(ctx, sql, params, b.DriverName)
+}
+
+// BuildQuery skips the sort and excluding fields, which do not exist; CheckSearchModel returns them as error
 func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driverName string) (string, []interface{}) {
+	return buildQuery(sm, tableName, modelType, driverName, nil)
+}
+
+// buildQuery does not search the keyword by the hidden fields, which are the json names of the search model
+func buildQuery(sm interface{}, tableName string, modelType reflect.Type, driverName string, hidden map[string]bool) (string, []interface{}) {
 	s1 := ""
 	rawConditions := make([]string, 0)
+	keywordColumns := make([]string, 0)
+	keywordValues := make([]interface{}, 0)
 	queryValues := make([]interface{}, 0)
 	sortString := ""
 	fields := make([]string, 0)
@@ -62,15 +104,17 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 	}
 
 	value := reflect.Indirect(reflect.ValueOf(sm))
-	typeOfValue := value.Type()
-	numField := value.NumField()
+	metadata := GetMetadata(value.Type())
 	marker := 0
 
-	for i := 0; i < numField; i++ {
+	for i := range metadata.Fields {
 		field := value.Field(i)
 		kind := field.Kind()
 		x := field.Interface()
-		typeOfField := value.Type().Field(i)
+		if v, ok := x.(SearchModel); ok {
+			x = &v
+		}
+		fieldMetadata := &metadata.Fields[i]
 		param := BuildParam(marker+1, driverName)
 
 		if v, ok := x.(*SearchModel); ok {
@@ -101,13 +145,12 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 			}
 		}
 
-		columnName, existCol := GetColumnName(value.Type(), typeOfField.Name)
+		columnName, existCol := GetColumnName(value.Type(), fieldMetadata.Name)
 		if !existCol {
-			columnName, _ = GetColumnName(modelType, typeOfField.Name)
+			columnName, _ = GetColumnName(modelType, fieldMetadata.Name)
 		}
-		columnNameFromSqlBuilderTag := GetColumnNameFromSqlBuilderTag(typeOfField)
-		if columnNameFromSqlBuilderTag != nil {
-			columnName = *columnNameFromSqlBuilderTag
+		if fieldMetadata.SqlBuilderColumn != nil {
+			columnName = *fieldMetadata.SqlBuilderColumn
 		}
 		if kind == reflect.Ptr && field.IsNil() {
 			continue
@@ -121,11 +164,11 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 				for key, val := range v.Excluding {
 					index, _, columnName := GetFieldByJson(value.Type(), key)
 					if index == -1 || columnName == "" {
-						log.Panic("column name not found")
+						continue
 					}
 					if len(val) > 0 {
 						format := fmt.Sprintf("(%s)", BuildParametersFrom(marker, len(val), driverName))
-						marker += len(val) - 1
+						marker += len(val)
 						rawConditions = append(rawConditions, fmt.Sprintf("%s NOT IN %s", columnName, format))
 						queryValues = ExtractArray(queryValues, val)
 					}
@@ -135,72 +178,72 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 			}
 			continue
 		} else if dateRange, ok := x.(DateRange); ok {
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, param))
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, BuildParam(marker+1, driverName)))
 			queryValues = append(queryValues, dateRange.StartDate)
+			marker++
 			var eDate = dateRange.EndDate.Add(time.Hour * 24)
-			dateRange.EndDate = &eDate
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, param))
-			queryValues = append(queryValues, dateRange.EndDate)
-			marker += 2
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, BuildParam(marker+1, driverName)))
+			queryValues = append(queryValues, &eDate)
+			marker++
 		} else if dateRange, ok := x.(*DateRange); ok && dateRange != nil {
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, param))
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, BuildParam(marker+1, driverName)))
 			queryValues = append(queryValues, dateRange.StartDate)
+			marker++
 			var eDate = dateRange.EndDate.Add(time.Hour * 24)
-			dateRange.EndDate = &eDate
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, param))
-			queryValues = append(queryValues, dateRange.EndDate)
-			marker += 2
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, BuildParam(marker+1, driverName)))
+			queryValues = append(queryValues, &eDate)
+			marker++
 		} else if dateTime, ok := x.(TimeRange); ok {
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, param))
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, BuildParam(marker+1, driverName)))
 			queryValues = append(queryValues, dateTime.StartTime)
+			marker++
 			var eDate = dateTime.EndTime.Add(time.Hour * 24)
-			dateTime.EndTime = &eDate
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, param))
-			queryValues = append(queryValues, dateTime.EndTime)
-			marker += 2
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, BuildParam(marker+1, driverName)))
+			queryValues = append(queryValues, &eDate)
+			marker++
 		} else if dateTime, ok := x.(*TimeRange); ok && dateTime != nil {
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, param))
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, BuildParam(marker+1, driverName)))
 			queryValues = append(queryValues, dateTime.StartTime)
+			marker++
 			var eDate = dateTime.EndTime.Add(time.Hour * 24)
-			dateTime.EndTime = &eDate
-			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, param))
-			queryValues = append(queryValues, dateTime.EndTime)
-			marker += 2
+			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, BuildParam(marker+1, driverName)))
+			queryValues = append(queryValues, &eDate)
+			marker++
 		} else if numberRange, ok := x.(NumberRange); ok {
 			if numberRange.Min != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Min)
 				marker++
 			} else if numberRange.Lower != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Lower)
 				marker++
 			}
 			if numberRange.Max != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterEqualThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterEqualThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Max)
 				marker++
 			} else if numberRange.Upper != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Upper)
 				marker++
 			}
 		} else if numberRange, ok := x.(*NumberRange); ok && numberRange != nil {
 			if numberRange.Min != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterEqualThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Min)
 				marker++
 			} else if numberRange.Lower != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, GreaterThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Lower)
 				marker++
 			}
 			if numberRange.Max != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterEqualThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterEqualThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Max)
 				marker++
 			} else if numberRange.Upper != nil {
-				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, param))
+				rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, LighterThan, BuildParam(marker+1, driverName)))
 				queryValues = append(queryValues, numberRange.Upper)
 				marker++
 			}
@@ -208,7 +251,7 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 			var searchValue bool
 			if field.Len() > 0 {
 				const defaultKey = "contain"
-				if key, ok := typeOfValue.Field(i).Tag.Lookup("match"); ok {
+				if key, ok := fieldMetadata.Match, fieldMetadata.HasMatch; ok {
 					if format, exist := keywordFormat[key]; exist {
 						searchValue = true
 						value2, valid := x.(string)
@@ -256,23 +299,24 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 					//rawConditions = append(rawConditions, fmt.Sprintf("%s %s ?", columnName, Like))
 					queryValues = append(queryValues, value2)
 				}
-			} else if len(keyword) > 0 {
-				if key, ok := typeOfValue.Field(i).Tag.Lookup("keyword"); ok {
+			} else if len(keyword) > 0 && !hidden[fieldMetadata.Json] {
+				if key, ok := fieldMetadata.Keyword, fieldMetadata.HasKeyword; ok {
 					if format, exist := keywordFormat[key]; exist {
 						//if sql == "mysql" {
 						//	keyword = EscapeString(keyword)
 						//} else if sql == "postgres" || sql == "mssql" {
 						//	keyword = EscapeStringForSelect(keyword)
 						//}
+						var keyword2 string
 						if format == `?%` {
-							keyword = keyword + `%`
+							keyword2 = keyword + `%`
 						} else if format == `%?%` {
-							keyword = `%` + keyword + `%`
+							keyword2 = `%` + keyword + `%`
 						} else {
 							log.Panicf("keyword not support \"%v\" format\n", key)
 						}
-
-						queryValues = append(queryValues, keyword)
+						keywordColumns = append(keywordColumns, columnName)
+						keywordValues = append(keywordValues, keyword2)
 					} else {
 						log.Panicf("keyword not support \"%v\" format\n", key)
 					}
@@ -296,8 +340,23 @@ func BuildQuery(sm interface{}, tableName string, modelType reflect.Type, driver
 		} else {
 			rawConditions = append(rawConditions, fmt.Sprintf("%s %s %s", columnName, Exact, param))
 			queryValues = append(queryValues, x)
+			marker++
 		}
 	}
+	// the keyword conditions are the last condition, so their params are numbered and added after the params of the other conditions
+	if len(keywordColumns) > 0 {
+		keywordConditions := make([]string, 0)
+		for i, columnName := range keywordColumns {
+			if driverName == DriverPostgres {
+				keywordConditions = append(keywordConditions, fmt.Sprintf("%s %s %s", columnName, `ilike`, BuildParam(marker+1, driverName)))
+			} else {
+				keywordConditions = append(keywordConditions, fmt.Sprintf("%s %s %s", columnName, Like, BuildParam(marker+1, driverName)))
+			}
+			queryValues = append(queryValues, keywordValues[i])
+			marker++
+		}
+		rawConditions = append(rawConditions, "("+strings.Join(keywordConditions, " OR ")+")")
+	}
 	if len(rawConditions) > 0 {
 		s2 := s1 + ` where ` + strings.Join(rawConditions, " AND ") + sortString
 		return s2, queryValues
@@ -318,17 +377,75 @@ func BuildSort(sortString string, modelType reflect.Type) string {
 	sorts := strings.Split(sortString, ",")
 	for i := 0; i < len(sorts); i++ {
 		sortField := strings.TrimSpace(sorts[i])
+		if len(sortField) == 0 {
+			continue
+		}
 		fieldName := sortField
 		c := sortField[0:1]
 		if c == "-" || c == "+" {
-			fieldName = sortField[1:]
+			fieldName = strings.TrimSpace(sortField[1:])
+		}
+		if !IsSortable(modelType, fieldName) {
+			continue
 		}
 		columnName := GetColumnNameForSearch(modelType, fieldName)
 		sortType := GetSortType(c)
 		sort = append(sort, columnName+" "+sortType)
 	}
+	if len(sort) == 0 {
+		return ""
+	}
 	return ` order by ` + strings.Join(sort, ",")
 }
+
+// CheckSearchModel returns the error of the sort fields of the model and the excluding fields of the search model, which do not exist
+func CheckSearchModel(sm interface{}, modelType reflect.Type) error {
+	s := GetSearchModel(sm)
+	if s == nil {
+		return nil
+	}
+	if err := CheckSort(s.Sort, modelType); err != nil {
+		return err
+	}
+	if len(s.Excluding) > 0 {
+		searchModelType := reflect.Indirect(reflect.ValueOf(sm)).Type()
+		for key := range s.Excluding {
+			if index, _, columnName := GetFieldByJson(searchModelType, key); index == -1 || columnName == "" {
+				return NewUnknownFieldError(key)
+			}
+		}
+	}
+	return nil
+}
+func CheckSort(sortString string, modelType reflect.Type) error {
+	for _, sortField := range strings.Split(sortString, ",") {
+		sortField = strings.TrimSpace(sortField)
+		if len(sortField) == 0 {
+			continue
+		}
+		fieldName := sortField
+		if c := sortField[0:1]; c == "-" || c == "+" {
+			fieldName = strings.TrimSpace(sortField[1:])
+		}
+		if !IsSortable(modelType, fieldName) {
+			return NewInvalidSortError(fieldName)
+		}
+	}
+	return nil
+}
+
+// IsSortable checks the sort field is a json name or a column of the model
+func IsSortable(modelType reflect.Type, fieldName string) bool {
+	if i, _, _ := GetFieldByJson(modelType, fieldName); i >= 0 {
+		return true
+	}
+	for _, column := range GetColumnsSelect(modelType) {
+		if column == fieldName {
+			return true
+		}
+	}
+	return false
+}
 func ReplaceParameters(sql string, number int, prefix string) string {
 	for i := 0; i < number; i++ {
 		count := i + 1
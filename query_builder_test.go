@@ -0,0 +1,84 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type accountSM struct {
+	*SearchModel
+	Username string     `json:"username" gorm:"column:username" keyword:"prefix"`
+	Email    string     `json:"email" gorm:"column:email" keyword:"contain"`
+	Status   []string   `json:"status" gorm:"column:status"`
+	Age      *int64     `json:"age" gorm:"column:age"`
+	Created  *DateRange `json:"created" gorm:"column:created"`
+}
+type account struct {
+	Username string    `json:"username" gorm:"column:username"`
+	Email    string    `json:"email" gorm:"column:email"`
+	Status   string    `json:"status" gorm:"column:status"`
+	Age      int64     `json:"age" gorm:"column:age"`
+	Created  time.Time `json:"created" gorm:"column:created"`
+}
+
+func newAccountSM() *accountSM {
+	age := int64(30)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	return &accountSM{SearchModel: &SearchModel{Keyword: " jo "}, Status: []string{"A", "I"}, Age: &age, Created: &DateRange{StartDate: &start, EndDate: &end}}
+}
+
+func TestBuildQueryKeywordParams(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		driver string
+		where  string
+	}{
+		{DriverMysql, "status in (?,?) AND age = ? AND created >= ? AND created < ? AND (username like ? OR email like ?)"},
+		{DriverPostgres, "status in ($1,$2) AND age = $3 AND created >= $4 AND created < $5 AND (username ilike $6 OR email ilike $7)"},
+		{DriverOracle, "status in (:val1,:val2) AND age = :val3 AND created >= :val4 AND created < :val5 AND (username like :val6 OR email like :val7)"},
+	}
+	for _, c := range cases {
+		sm := newAccountSM()
+		sql, params := BuildQuery(sm, "accounts", reflect.TypeOf(account{}), c.driver)
+		if want := "select  username,email,status,age,created from accounts where " + c.where; sql != want {
+			t.Errorf("%s: sql = %q, want %q", c.driver, sql, want)
+		}
+		if len(params) != 7 {
+			t.Fatalf("%s: params = %v", c.driver, params)
+		}
+		if !reflect.DeepEqual(params[0:2], []interface{}{"A", "I"}) || *params[2].(*int64) != 30 || !params[3].(*time.Time).Equal(start) || !params[4].(*time.Time).Equal(end) || params[5] != "jo%" || params[6] != "%jo%" {
+			t.Errorf("%s: params = %v", c.driver, params)
+		}
+		if !sm.Created.EndDate.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("%s: the end date of the search model must not be changed: %v", c.driver, sm.Created.EndDate)
+		}
+	}
+}
+
+func TestBuildQueryExcluding(t *testing.T) {
+	sm := &accountSM{SearchModel: &SearchModel{Excluding: map[string][]interface{}{"username": {"root", "admin"}}, Sort: "-age"}, Status: []string{"A"}}
+	sql, params := BuildQuery(sm, "accounts", reflect.TypeOf(account{}), DriverPostgres)
+	if want := "select  username,email,status,age,created from accounts where username NOT IN ($1,$2) AND status in ($3) order by age desc"; sql != want {
+		t.Errorf("sql = %q, want %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []interface{}{"root", "admin", "A"}) {
+		t.Errorf("params = %v", params)
+	}
+}
+
+// every placeholder must have its param, in the order of the placeholders
+func TestBuildQueryPlaceholders(t *testing.T) {
+	sm := newAccountSM()
+	sm.Email = "test"
+	sql, params := BuildQuery(sm, "accounts", reflect.TypeOf(account{}), DriverMysql)
+	if n := strings.Count(sql, "?"); n != len(params) {
+		t.Errorf("%d placeholders, %d params: %s %v", n, len(params), sql, params)
+	}
+	if !strings.HasSuffix(sql, "email like ? AND status in (?,?) AND age = ? AND created >= ? AND created < ? AND (username like ?)") || params[0] != "%test%" || params[6] != "jo%" {
+		t.Errorf("sql = %q, params = %v", sql, params)
+	}
+}
@@ -3,10 +3,12 @@ package search
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -22,11 +24,35 @@ const (
 )
 
 type SearchBuilder struct {
-	Database      *sql.DB
+	// *sql.DB, *sql.Tx, *sql.Conn or Router; the transaction of the context, set by WithTx, is used first
+	Database Querier
+	// the driver of Database, such as DriverPostgres, set by the constructors; if empty, it is detected by GetDriver,
+	// which cannot detect the driver of *sql.Tx and *sql.Conn
+	Driver        string
 	BuildQuery    func(sm interface{}) (string, []interface{})
 	ModelType     reflect.Type
 	extractSearch func(m interface{}) (int64, int64, int64, error)
 	Map           func(ctx context.Context, model interface{}) (interface{}, error)
+	// if not nil, it is used instead of BuildQuery, such as QueryBuilder.BuildQueryWithContext for tenant routing
+	BuildQueryWithContext func(ctx context.Context, sm interface{}) (string, []interface{}, error)
+	// adds the tenant condition to the query of BuildQuery or BuildQueryWithContext
+	Tenant *TenantConfig
+	// row level predicates by resource; the resource is Resource or the one of context, set by SearchHandler
+	Policies *PolicyRegistry
+	Resource string
+	// receives the query, count and map stages, if the context has no instrument
+	Instrument Instrument
+	// count strategy; if nil, the total is counted exactly
+	Counting *CountConfig
+	// if the count does not finish in time, the page is returned with the total -1, and TotalInfo tells whether there is next page
+	CountTimeout time.Duration
+	// runs the page and count queries in one read only transaction, to get the total of the same snapshot as the page
+	Snapshot bool
+	// runs the count query at the same time as the page query, so CountTimeout starts with the page query;
+	// in a transaction, such as the one of Snapshot or WithTx, the count runs after the page, because a transaction runs one query at a time
+	ParallelCount bool
+	// the max number of histogram buckets; if 0, MaxBucketsDefault is used
+	MaxBuckets int
 }
 
 func NewSearchBuilder(db *sql.DB, modelType reflect.Type, buildQuery func(sm interface{}) (string, []interface{}), options ...func(context.Context, interface{}) (interface{}, error)) *SearchBuilder {
@@ -34,15 +60,17 @@ func NewSearchBuilder(db *sql.DB, modelType reflect.Type, buildQuery func(sm int
 	if len(options) >= 1 {
 		mp = options[0]
 	}
-	builder := &SearchBuilder{Database: db, BuildQuery: buildQuery, ModelType: modelType, Map: mp, extractSearch: ExtractSearch}
+	builder := &SearchBuilder{Database: db, Driver: GetDriver(db), BuildQuery: buildQuery, ModelType: modelType, Map: mp, extractSearch: ExtractSearch}
 	return builder
 }
 func NewSearchBuilderWithMap(db *sql.DB, modelType reflect.Type, buildQuery func(sm interface{}) (string, []interface{}), mp func(context.Context, interface{}) (interface{}, error), options ...func(m interface{}) (int64, int64, int64, error)) *SearchBuilder {
 	var extractSearch func(m interface{}) (int64, int64, int64, error)
-	if len(options) >= 1 {
+	if len(options) >= 1 && options[0] != nil {
 		extractSearch = options[0]
+	} else {
+		extractSearch = ExtractSearch
 	}
-	builder := &SearchBuilder{Database: db, BuildQuery: buildQuery, ModelType: modelType, extractSearch: extractSearch, Map: mp}
+	builder := &SearchBuilder{Database: db, Driver: GetDriver(db), BuildQuery: buildQuery, ModelType: modelType, extractSearch: extractSearch, Map: mp}
 	return builder
 }
 func NewDefaultSearchBuilder(db *sql.DB, tableName string, modelType reflect.Type, mp func(context.Context, interface{}) (interface{}, error), options ...func(m interface{}) (int64, int64, int64, error)) *SearchBuilder {
@@ -52,28 +80,265 @@ func NewDefaultSearchBuilder(db *sql.DB, tableName string, modelType reflect.Typ
 	}
 	driverName := GetDriver(db)
 	queryBuilder := NewDefaultQueryBuilder(tableName, modelType, driverName)
-	return NewSearchBuilderWithMap(db, modelType, queryBuilder.BuildQuery, mp, extractSearch)
+	builder := NewSearchBuilderWithMap(db, modelType, queryBuilder.BuildQuery, mp, extractSearch)
+	builder.BuildQueryWithContext = queryBuilder.BuildQueryWithContext
+	return builder
+}
+func NewTenantSearchBuilder(db *sql.DB, tableName string, modelType reflect.Type, tenant *TenantConfig, mp func(context.Context, interface{}) (interface{}, error), options ...func(m interface{}) (int64, int64, int64, error)) *SearchBuilder {
+	var extractSearch func(m interface{}) (int64, int64, int64, error)
+	if len(options) >= 1 {
+		extractSearch = options[0]
+	}
+	driverName := GetDriver(db)
+	queryBuilder := NewTenantQueryBuilder(tableName, modelType, driverName, tenant)
+	builder := NewSearchBuilderWithMap(db, modelType, queryBuilder.BuildQuery, mp, extractSearch)
+	builder.BuildQueryWithContext = queryBuilder.BuildQueryWithContext
+	return builder
+}
+
+// Build builds the query of the search model, with the tenant condition if any; the sort and excluding fields, which do not exist, are rejected
+func (b *SearchBuilder) Build(ctx context.Context, m interface{}) (string, []interface{}, error) {
+	if err := CheckSearchModel(m, b.ModelType); err != nil {
+		return "", nil, err
+	}
+	var sql string
+	var params []interface{}
+	if b.BuildQueryWithContext != nil {
+		var err error
+		sql, params, err = b.BuildQueryWithContext(ctx, m)
+		if err != nil {
+			return sql, params, err
+		}
+	} else {
+		// BuildQuery cannot skip the keyword of the hidden fields of the context
+		if name, ok := hiddenKeyword(m, GetHiddenFields(ctx)); ok {
+			return "", nil, NewForbiddenError("cannot search keyword by " + name)
+		}
+		sql, params = b.BuildQuery(m)
+	}
+	if b.Tenant != nil {
+		if b.Tenant.Mode == TenantSchema || b.Tenant.Mode == TenantTable {
+			return "", nil, errors.New("tenant routing requires QueryBuilder.BuildQueryWithContext")
+		}
+		var err error
+		sql, params, err = b.Tenant.Apply(ctx, sql, params, b.driver())
+		if err != nil {
+			return sql, params, err
+		}
+	}
+	if b.Policies != nil {
+		resource := b.Resource
+		if len(resource) == 0 {
+			resource = GetResource(ctx)
+		}
+		return b.Policies.Apply(ctx, resource, sql, params, b.driver())
+	}
+	return sql, params, nil
 }
 func (b *SearchBuilder) Search(ctx context.Context, m interface{}) (interface{}, int64, error) {
-	sql, params := b.BuildQuery(m)
+	ctx = b.instrument(ctx)
+	sql, params, err := b.Build(ctx, m)
+	if err != nil {
+		return nil, 0, err
+	}
 	pageIndex, pageSize, firstPageSize, err := b.extractSearch(m)
 	if err != nil {
 		return nil, 0, err
 	}
-	return BuildFromQuery(ctx, b.Database, b.ModelType, sql, params, pageIndex, pageSize, firstPageSize, b.Map)
+	if b.Snapshot && pageSize > 0 && GetTx(ctx) == nil {
+		var models interface{}
+		var total int64
+		err := RunInSnapshot(ctx, b.db(), func(ctx context.Context) error {
+			var er1 error
+			models, total, er1 = b.search(ctx, sql, params, pageIndex, pageSize, firstPageSize)
+			return er1
+		})
+		return models, total, err
+	}
+	return b.search(ctx, sql, params, pageIndex, pageSize, firstPageSize)
+}
+func (b *SearchBuilder) search(ctx context.Context, sql string, params []interface{}, pageIndex int64, pageSize int64, firstPageSize int64) (interface{}, int64, error) {
+	if pageSize > 0 && (b.CountTimeout > 0 || b.Counting != nil || b.ParallelCount) {
+		return b.searchAndCount(ctx, sql, params, pageIndex, pageSize, firstPageSize)
+	}
+	return BuildFromQuery(ctx, b.db(), b.ModelType, sql, params, pageIndex, pageSize, firstPageSize, b.Map)
+}
+
+func (b *SearchBuilder) Count(ctx context.Context, m interface{}) (int64, error) {
+	ctx = b.instrument(ctx)
+	sql, params, err := b.Build(ctx, m)
+	if err != nil {
+		return 0, err
+	}
+	if b.Counting != nil {
+		total, exact, err := b.count(ctx, sql, params)
+		if info := GetTotalInfo(ctx); info != nil {
+			info.Strategy = b.Counting.Strategy
+			info.Exact = exact
+		}
+		return total, err
+	}
+	return BuildCountFromQuery(ctx, b.db(), sql, params)
+}
+func (b *SearchBuilder) searchAndCount(ctx context.Context, sql string, params []interface{}, pageIndex int64, pageSize int64, firstPageSize int64) (interface{}, int64, error) {
+	strategy := CountExact
+	if b.Counting != nil {
+		strategy = b.Counting.Strategy
+	}
+	info := GetTotalInfo(ctx)
+	if info != nil {
+		info.Strategy = strategy
+	}
+	count := b.startCount(ctx, sql, params)
+	if b.CountTimeout <= 0 {
+		models, err := QueryPage(ctx, b.db(), b.ModelType, sql, params, pageIndex, pageSize, firstPageSize)
+		if err != nil {
+			count(true)
+			return nil, -1, err
+		}
+		total, exact, err := count(false)
+		if err != nil {
+			return nil, -1, err
+		}
+		if info != nil {
+			info.Exact = exact
+		}
+		return BuildSearchResult(ctx, models, total, b.Map)
+	}
+	models, hasNext, err := QueryPageAndNext(ctx, b.db(), b.ModelType, sql, params, pageIndex, pageSize, firstPageSize)
+	if err != nil {
+		count(true)
+		return nil, -1, err
+	}
+	total, exact, err := count(false)
+	if err != nil && err != context.DeadlineExceeded {
+		return nil, -1, err
+	}
+	if err != nil {
+		if info != nil {
+			info.Exact = false
+			info.Unknown = true
+			info.HasNext = hasNext
+		}
+		return BuildSearchResult(ctx, models, -1, b.Map)
+	}
+	if info != nil {
+		info.Exact = exact
+	}
+	return BuildSearchResult(ctx, models, total, b.Map)
 }
 
-func BuildFromQuery(ctx context.Context, db *sql.DB, modelType reflect.Type, query string, params []interface{}, pageIndex int64, pageSize int64, initPageSize int64, mp func(context.Context, interface{}) (interface{}, error)) (interface{}, int64, error) {
+// startCount starts the count in a goroutine if ParallelCount is set and the context has no transaction, else the count runs when it is waited for.
+// The returned func waits for the count within CountTimeout; if stop is true, the count is canceled.
+// If the count does not finish within CountTimeout, the error is context.DeadlineExceeded, whatever the error of the driver is.
+func (b *SearchBuilder) startCount(ctx context.Context, sql string, params []interface{}) func(stop bool) (int64, bool, error) {
+	if !b.ParallelCount || GetTx(ctx) != nil {
+		return func(stop bool) (int64, bool, error) {
+			if stop {
+				return 0, false, nil
+			}
+			if b.CountTimeout <= 0 {
+				return b.count(ctx, sql, params)
+			}
+			ctx2, cancel := context.WithTimeout(ctx, b.CountTimeout)
+			defer cancel()
+			total, exact, err := b.count(ctx2, sql, params)
+			return total, exact, countTimedOut(ctx, ctx2, err)
+		}
+	}
+	var ctx2 context.Context
+	var cancel context.CancelFunc
+	if b.CountTimeout > 0 {
+		ctx2, cancel = context.WithTimeout(ctx, b.CountTimeout)
+	} else {
+		ctx2, cancel = context.WithCancel(ctx)
+	}
+	type result struct {
+		total int64
+		exact bool
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				ch <- result{err: RecoverToError(v)}
+			}
+		}()
+		total, exact, err := b.count(ctx2, sql, params)
+		ch <- result{total, exact, err}
+	}()
+	return func(stop bool) (int64, bool, error) {
+		if stop {
+			cancel()
+		}
+		r := <-ch
+		err := countTimedOut(ctx, ctx2, r.err)
+		cancel()
+		return r.total, r.exact, err
+	}
+}
+
+// countTimedOut returns context.DeadlineExceeded if the count failed by the deadline of CountTimeout, not by the one of the search
+func countTimedOut(ctx context.Context, countCtx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil && countCtx.Err() == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+func (b *SearchBuilder) count(ctx context.Context, sql string, params []interface{}) (int64, bool, error) {
+	if b.Counting != nil {
+		return b.Counting.Count(ctx, b.db(), sql, params)
+	}
+	total, err := BuildCountFromQuery(ctx, b.db(), sql, params)
+	return total, err == nil, err
+}
+
+// db returns Database, which tells GetDriver the Driver of the builder, for *sql.Tx and *sql.Conn
+func (b *SearchBuilder) db() Querier {
+	if len(b.Driver) == 0 || b.Database == nil || GetDriver(b.Database) == b.Driver {
+		return b.Database
+	}
+	return WithDriver(b.Database, b.Driver)
+}
+func (b *SearchBuilder) driver() string {
+	if len(b.Driver) > 0 {
+		return b.Driver
+	}
+	return GetDriver(b.Database)
+}
+
+// instrument sets the Instrument and the Resource of the builder to the context without them, so that the stages have the resource label without SearchHandler
+func (b *SearchBuilder) instrument(ctx context.Context) context.Context {
+	if b.Instrument != nil && GetInstrument(ctx) == nil {
+		ctx = WithInstrument(ctx, b.Instrument)
+	}
+	if len(b.Resource) > 0 && len(GetResource(ctx)) == 0 {
+		ctx = WithResource(ctx, b.Resource)
+	}
+	return ctx
+}
+func BuildCountFromQuery(ctx context.Context, db Querier, query string, params []interface{}) (int64, error) {
+	queryCount, paramsCount := BuildCountQuery(query, params)
+	ctx2, end := StartSpan(ctx, StageCount)
+	total, err := CountWithContext(ctx2, db, queryCount, paramsCount...)
+	end(total, err)
+	return total, err
+}
+
+func BuildFromQuery(ctx context.Context, db Querier, modelType reflect.Type, query string, params []interface{}, pageIndex int64, pageSize int64, initPageSize int64, mp func(context.Context, interface{}) (interface{}, error)) (interface{}, int64, error) {
 	var total int64
 	modelsType := reflect.Zero(reflect.SliceOf(modelType)).Type()
 	models := reflect.New(modelsType).Interface()
 	driverName := GetDriver(db)
 	if pageSize <= 0 {
-		fieldsIndex, er12 := GetColumnIndexes(modelType, driverName)
+		fieldsIndex, er12 := getColumnIndexes(modelType, driverName)
 		if er12 != nil {
 			return nil, -1, er12
 		}
-		er1 := Query(db, models, fieldsIndex, query, params...)
+		ctx2, end := StartSpan(ctx, StageQuery)
+		er1 := QueryWithContext(ctx2, db, models, fieldsIndex, query, params...)
+		end(GetResultCount(models), er1)
 		if er1 != nil {
 			return nil, -1, er1
 		}
@@ -86,23 +351,22 @@ func BuildFromQuery(ctx context.Context, db *sql.DB, modelType reflect.Type, que
 	} else {
 		if driverName == DriverOracle {
 			queryPaging := BuildPagingQueryByDriver(query, pageIndex, pageSize, initPageSize, driverName)
-			er1 := QueryAndCount(db, models, &total, driverName, queryPaging, params...)
+			ctx2, end := StartSpan(ctx, StageQuery)
+			er1 := QueryAndCountWithContext(ctx2, db, models, &total, driverName, queryPaging, params...)
+			end(GetResultCount(models), er1)
 			if er1 != nil {
 				return nil, -1, er1
 			}
 			return BuildSearchResult(ctx, models, total, mp)
 		} else {
-			queryPaging := BuildPagingQuery(query, pageIndex, pageSize, initPageSize, driverName)
 			queryCount, paramsCount := BuildCountQuery(query, params)
-			fieldsIndex, er12 := GetColumnIndexes(modelType, driverName)
-			if er12 != nil {
-				return nil, -1, er12
-			}
-			er1 := Query(db, models, fieldsIndex, queryPaging, params...)
+			models, er1 := QueryPage(ctx, db, modelType, query, params, pageIndex, pageSize, initPageSize)
 			if er1 != nil {
 				return nil, -1, er1
 			}
-			total, er2 := Count(db, queryCount, paramsCount...)
+			ctx2, endCount := StartSpan(ctx, StageCount)
+			total, er2 := CountWithContext(ctx2, db, queryCount, paramsCount...)
+			endCount(total, er2)
 			if er2 != nil {
 				total = 0
 			}
@@ -110,6 +374,59 @@ func BuildFromQuery(ctx context.Context, db *sql.DB, modelType reflect.Type, que
 		}
 	}
 }
+
+// QueryPage queries the page of the query, without count
+func QueryPage(ctx context.Context, db Querier, modelType reflect.Type, query string, params []interface{}, pageIndex int64, pageSize int64, initPageSize int64) (interface{}, error) {
+	modelsType := reflect.Zero(reflect.SliceOf(modelType)).Type()
+	models := reflect.New(modelsType).Interface()
+	driverName := GetDriver(db)
+	queryPaging := BuildPagingQuery(query, pageIndex, pageSize, initPageSize, driverName)
+	fieldsIndex, err := getColumnIndexes(modelType, driverName)
+	if err != nil {
+		return nil, err
+	}
+	ctx2, end := StartSpan(ctx, StageQuery)
+	err = QueryWithContext(ctx2, db, models, fieldsIndex, queryPaging, params...)
+	end(GetResultCount(models), err)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// QueryPageAndNext queries pageSize+1 rows of the page, to know whether there is next page without count
+func QueryPageAndNext(ctx context.Context, db Querier, modelType reflect.Type, query string, params []interface{}, pageIndex int64, pageSize int64, initPageSize int64) (interface{}, bool, error) {
+	modelsType := reflect.Zero(reflect.SliceOf(modelType)).Type()
+	models := reflect.New(modelsType).Interface()
+	driverName := GetDriver(db)
+	limit := pageSize
+	if initPageSize > 0 && pageIndex <= 1 {
+		limit = initPageSize
+	}
+	offset := GetOffset(pageIndex, pageSize, initPageSize)
+	var queryPaging string
+	if driverName == DriverOracle {
+		queryPaging = query + fmt.Sprintf(OraclePagingFormat, strconv.FormatInt(offset, 10), strconv.FormatInt(limit+1, 10))
+	} else {
+		queryPaging = query + fmt.Sprintf(DefaultPagingFormat, strconv.FormatInt(limit+1, 10), strconv.FormatInt(offset, 10))
+	}
+	fieldsIndex, err := getColumnIndexes(modelType, driverName)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx2, end := StartSpan(ctx, StageQuery)
+	err = QueryWithContext(ctx2, db, models, fieldsIndex, queryPaging, params...)
+	end(GetResultCount(models), err)
+	if err != nil {
+		return nil, false, err
+	}
+	values := reflect.ValueOf(models).Elem()
+	if int64(values.Len()) > limit {
+		values.Set(values.Slice(0, int(limit)))
+		return models, true, nil
+	}
+	return models, false, nil
+}
 func BuildPagingQueryByDriver(sql string, pageIndex int64, pageSize int64, initPageSize int64, driver string) string {
 	s2 := BuildPagingQuery(sql, pageIndex, pageSize, initPageSize, driver)
 	if driver != DriverOracle {
@@ -181,15 +498,27 @@ func BuildSearchResult(ctx context.Context, models interface{}, count int64, mp
 	if mp == nil {
 		return models, count, nil
 	}
-	r2, er3 := dbToModels(ctx, models, mp)
+	ctx2, end := StartSpan(ctx, StageMap)
+	r2, er3 := dbToModels(ctx2, models, mp)
+	end(GetResultCount(r2), er3)
 	return r2, count, er3
 }
 
-func GetDriver(db *sql.DB) string {
+func GetDriver(db Querier) string {
 	if db == nil {
 		return DriverNotSupport
 	}
-	driver := reflect.TypeOf(db.Driver()).String()
+	if v := reflect.ValueOf(db); v.Kind() == reflect.Ptr && v.IsNil() {
+		return DriverNotSupport
+	}
+	if d, ok := db.(driverNamer); ok {
+		return d.DriverName()
+	}
+	d, ok := db.(driverGetter)
+	if !ok {
+		return DriverNotSupport
+	}
+	driver := reflect.TypeOf(d.Driver()).String()
 	switch driver {
 	case "*pq.Driver":
 		return DriverPostgres
@@ -236,7 +565,7 @@ func dbToModels(ctx context.Context, models interface{}, mp func(context.Context
 			if k == reflect.Struct {
 				y := x.Addr().Interface()
 				mp(ctx, y)
-			} else  {
+			} else {
 				y := x.Interface()
 				mp(ctx, y)
 			}
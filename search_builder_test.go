@@ -0,0 +1,125 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSlowCountDB answers the count query after the delay, or the end of the context, and the page query at once
+func newSlowCountDB(t *testing.T, delay time.Duration) (*SearchBuilder, *fakeDB) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			select {
+			case <-time.After(delay):
+				return totalOf(25), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john"), testUserRow("2", "joe"), testUserRow("3", "jim")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	return NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery), f
+}
+
+func TestCountTimeoutReturnsHasNext(t *testing.T) {
+	builder, f := newSlowCountDB(t, time.Second)
+	builder.CountTimeout = 20 * time.Millisecond
+	h := NewSearchHandlerWithOptions(builder.Search, reflect.TypeOf(testUserSM{}), HandlerOptions{Counter: builder.Count}, nil, nil)
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?limit=2", nil))
+	var result map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if _, ok := result["total"]; ok || result["hasNext"] != true {
+		t.Errorf("result = %v", result)
+	}
+	if results := result["results"].([]interface{}); len(results) != 2 {
+		t.Errorf("results = %v", results)
+	}
+	if q := f.Queries()[0].Query; q != "select  id,username,email,status,salary from users limit 3 offset 0 " {
+		t.Errorf("the page query must fetch pageSize+1 rows: %q", q)
+	}
+}
+
+func TestParallelCount(t *testing.T) {
+	started := make(chan struct{})
+	db, _ := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			close(started)
+			return totalOf(25), nil
+		}
+		select {
+		case <-started:
+			return rowsOf(testUserColumns, testUserRow("1", "john")), nil
+		case <-time.After(time.Second):
+			return nil, errors.New("the count did not run with the page query")
+		}
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	builder.ParallelCount = true
+	info := &TotalInfo{}
+	results, total, err := builder.Search(WithTotalInfo(context.Background(), info), &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}})
+	if err != nil || total != 25 || !info.Exact || len(*results.(*[]testUser)) != 1 {
+		t.Fatalf("results %v, total %d, err %v, info %+v", results, total, err, info)
+	}
+}
+
+func TestParallelCountTimeout(t *testing.T) {
+	builder, _ := newSlowCountDB(t, time.Second)
+	builder.ParallelCount = true
+	builder.CountTimeout = 20 * time.Millisecond
+	info := &TotalInfo{}
+	start := time.Now()
+	_, total, err := builder.Search(WithTotalInfo(context.Background(), info), &testUserSM{SearchModel: &SearchModel{Limit: 2, Page: 1}})
+	if err != nil || total != -1 || !info.Unknown || !info.HasNext {
+		t.Fatalf("total %d, err %v, info %+v", total, err, info)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("the search waited %s for the count", d)
+	}
+}
+
+func TestCountErrorIsNotUnknownTotal(t *testing.T) {
+	db, _ := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			return nil, errors.New("relation does not exist")
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john")), nil
+	})
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewSearchBuilder(db, modelType, NewDefaultQueryBuilder("users", modelType, GetDriver(db)).BuildQuery)
+	for _, c := range []struct {
+		parallel bool
+		timeout  time.Duration
+	}{{false, time.Second}, {true, 0}, {true, time.Second}} {
+		builder.ParallelCount = c.parallel
+		builder.CountTimeout = c.timeout
+		info := &TotalInfo{}
+		if _, _, err := builder.Search(WithTotalInfo(context.Background(), info), &testUserSM{SearchModel: &SearchModel{Limit: 2, Page: 1}}); err == nil || info.Unknown {
+			t.Errorf("%+v: the error of the count must be returned: %v, info %+v", c, err, info)
+		}
+	}
+}
+
+func TestParallelCountInSnapshot(t *testing.T) {
+	builder, f := newSlowCountDB(t, 0)
+	builder.ParallelCount = true
+	builder.Snapshot = true
+	_, total, err := builder.Search(context.Background(), &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}})
+	if err != nil || total != 25 {
+		t.Fatal(total, err)
+	}
+	queries := f.Queries()
+	if len(queries) != 2 || isCountQuery(queries[0].Query) || !queries[0].InTx || !queries[1].InTx {
+		t.Errorf("the snapshot must run the page, then the count, in the transaction: %+v", queries)
+	}
+}
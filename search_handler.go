@@ -7,6 +7,9 @@ import (
 	"strings"
 )
 
+type BeforeSearchHook func(ctx context.Context, searchModel interface{}) error
+type AfterSearchHook func(ctx context.Context, searchModel interface{}, results interface{}, total int64) (interface{}, error)
+
 type SearchHandler struct {
 	search                    func(ctx context.Context, searchModel interface{}) (interface{}, int64, error)
 	searchModelType           reflect.Type
@@ -19,6 +22,32 @@ type SearchHandler struct {
 	Action                    string
 	embedField                string
 	userId                    string
+	// run in order before search, such as to force tenant or owner filters; an error stops the search
+	BeforeSearch []BeforeSearchHook
+	// run in order after search, such as to redact results
+	AfterSearch []AfterSearchHook
+	// structured audit event of every request, such as JSONLinesAuditor.Audit or NewLogAuditor(writeLog)
+	Audit func(ctx context.Context, event AuditEvent) error
+	// page size policy; if nil, RepairSearchModel is used
+	Paging *PagingConfig
+	// validate the search model after the paging policy, before searching; all violations are returned as 400.
+	// Validate rejects a limit above MaxPageSizeDefault; use NewValidator for a larger MaxPageSize of Paging
+	Validate func(searchModel interface{}) []ErrorMessage
+	// count only, used by HEAD and Count; if nil, search is used
+	Counter func(ctx context.Context, searchModel interface{}) (int64, error)
+	// facet counts, added to the result map when the search model has facets
+	Facets func(ctx context.Context, searchModel interface{}) (map[string]map[string]int64, error)
+	// aggregates over the filtered set, such as sum(amount), added to the result map
+	Aggregate func(ctx context.Context, searchModel interface{}) (map[string]interface{}, error)
+	// date or numeric buckets, added to the result map when the search model has histogram
+	Histogram func(ctx context.Context, searchModel interface{}) ([]Bucket, error)
+	// return the results array as body, with X-Total-Count and Link headers;
+	// the body has no room for facets, aggregates and histogram, so the requests of them are rejected with 400
+	HeaderPaging bool
+	// receives the decode, search, query, count, map and encode stages, such as PrometheusInstrument
+	Instrument Instrument
+	// added by Use, to wrap Counter
+	middlewares []Middleware
 
 	// search by GET
 	paramIndex            map[string]int
@@ -51,11 +80,7 @@ func NewSearchHandlerWithQuickSearch(search func(ctx context.Context, searchMode
 	if len(options) >= 2 {
 		resource = options[1]
 	} else {
-		name := searchModelType.Name()
-		if len(name) >= 3 && strings.HasSuffix(name, "SM") {
-			name = name[0 : len(name)-2]
-		}
-		resource = BuildResourceName(name)
+		resource = BuildResourceNameFromType(searchModelType)
 	}
 	if len(options) >= 3 {
 		action = options[2]
@@ -75,11 +100,7 @@ func NewSearchHandlerWithUserIdAndQuickSearch(search func(context.Context, inter
 	if len(options) >= 1 {
 		resource = options[0]
 	} else {
-		name := searchModelType.Name()
-		if len(name) >= 3 && strings.HasSuffix(name, "SM") {
-			name = name[0 : len(name)-2]
-		}
-		resource = BuildResourceName(name)
+		resource = BuildResourceNameFromType(searchModelType)
 	}
 	if len(options) >= 2 {
 		action = options[1]
@@ -88,10 +109,60 @@ func NewSearchHandlerWithUserIdAndQuickSearch(search func(context.Context, inter
 	}
 	return NewSearchHandlerWithConfig(search, searchModelType, logError, nil, writeLog, quickSearch, resource, action, userId, "")
 }
+func NewSearchHandlerWithPaging(search func(context.Context, interface{}) (interface{}, int64, error), searchModelType reflect.Type, paging PagingConfig, logError func(context.Context, string), writeLog func(context.Context, string, string, bool, string) error, options ...string) *SearchHandler {
+	h := NewSearchHandlerWithQuickSearch(search, searchModelType, logError, writeLog, true, options...)
+	h.Paging = &paging
+	return h
+}
+
+// HandlerOptions are the optional funcs of SearchHandler, such as the ones of SearchBuilder by BuilderOptions
+type HandlerOptions struct {
+	Counter   func(ctx context.Context, searchModel interface{}) (int64, error)
+	Facets    func(ctx context.Context, searchModel interface{}) (map[string]map[string]int64, error)
+	Aggregate func(ctx context.Context, searchModel interface{}) (map[string]interface{}, error)
+	Histogram func(ctx context.Context, searchModel interface{}) ([]Bucket, error)
+	Validate  func(searchModel interface{}) []ErrorMessage
+}
+
+// BuilderOptions returns the count, facets, aggregate and histogram of the builder, with the default Validate
+func BuilderOptions(builder *SearchBuilder) HandlerOptions {
+	return HandlerOptions{Counter: builder.Count, Facets: builder.Facets, Aggregate: builder.Aggregate, Histogram: builder.Histogram, Validate: Validate}
+}
+func NewSearchHandlerWithOptions(search func(context.Context, interface{}) (interface{}, int64, error), searchModelType reflect.Type, handlerOptions HandlerOptions, logError func(context.Context, string), writeLog func(context.Context, string, string, bool, string) error, options ...string) *SearchHandler {
+	h := NewSearchHandlerWithQuickSearch(search, searchModelType, logError, writeLog, true, options...)
+	h.Counter = handlerOptions.Counter
+	h.Facets = handlerOptions.Facets
+	h.Aggregate = handlerOptions.Aggregate
+	h.Histogram = handlerOptions.Histogram
+	h.Validate = handlerOptions.Validate
+	return h
+}
+
+// NewSearchHandlerWithBuilder searches, counts, and builds facets, aggregates and histogram by the builder
+func NewSearchHandlerWithBuilder(builder *SearchBuilder, searchModelType reflect.Type, logError func(context.Context, string), writeLog func(context.Context, string, string, bool, string) error, options ...string) *SearchHandler {
+	return NewSearchHandlerWithOptions(builder.Search, searchModelType, BuilderOptions(builder), logError, writeLog, options...)
+}
 func NewDefaultSearchHandler(search func(ctx context.Context, searchModel interface{}) (interface{}, int64, error), searchModelType reflect.Type, resource string, logError func(context.Context, string), userId string, quickSearch bool, writeLog func(context.Context, string, string, bool, string) error) *SearchHandler {
 	return NewSearchHandlerWithConfig(search, searchModelType, logError, nil, writeLog, quickSearch, resource, Search, userId, "")
 }
-func NewSearchHandlerWithConfig(search func(ctx context.Context, searchModel interface{}) (interface{}, int64, error), searchModelType reflect.Type, logError func(context.Context, string), config *SearchResultConfig, writeLog func(context.Context, string, string, bool, string) error, quickSearch bool, resource string, action string, userId string, embedField string) *SearchHandler {
+
+// BuildResourceNameFromType returns the resource name of the search model type, such as "user-role" of UserRoleSM
+func BuildResourceNameFromType(searchModelType reflect.Type) string {
+	name := searchModelType.Name()
+	if len(name) >= 3 && strings.HasSuffix(name, "SM") {
+		name = name[0 : len(name)-2]
+	}
+	return BuildResourceName(name)
+}
+func NewSearchHandlerWithConfig(search func(ctx context.Context, searchModel interface{}) (interface{}, int64, error), searchModelType reflect.Type, logError func(context.Context, string), config *SearchResultConfig, writeLog func(context.Context, string, string, bool, string) error, quickSearch bool, resource string, action string, userId string, embedField string, options ...PagingConfig) *SearchHandler {
+	if IsExtendedFromSearchModel(searchModelType) == false {
+		panic(errors.New(searchModelType.Name() + " isn't SearchModel struct nor extended from SearchModel struct!"))
+	}
+	return newSearchHandler(search, searchModelType, logError, config, writeLog, quickSearch, resource, action, userId, embedField, options...)
+}
+
+// newSearchHandler does not check the search model type, which is checked by NewSearchHandlerWithConfig or by the type parameters of NewTypedSearchHandler
+func newSearchHandler(search func(ctx context.Context, searchModel interface{}) (interface{}, int64, error), searchModelType reflect.Type, logError func(context.Context, string), config *SearchResultConfig, writeLog func(context.Context, string, string, bool, string) error, quickSearch bool, resource string, action string, userId string, embedField string, options ...PagingConfig) *SearchHandler {
 	var c SearchResultConfig
 	if len(action) == 0 {
 		action = Search
@@ -103,14 +174,34 @@ func NewSearchHandlerWithConfig(search func(ctx context.Context, searchModel int
 		c.Results = "results"
 		c.Total = "total"
 	}
-	isExtendedSearchModelType := IsExtendedFromSearchModel(searchModelType)
-	if isExtendedSearchModelType == false {
-		panic(errors.New(searchModelType.Name() + " isn't SearchModel struct nor extended from SearchModel struct!"))
+	if len(c.Facets) == 0 {
+		c.Facets = "facets"
+	}
+	if len(c.Aggregates) == 0 {
+		c.Aggregates = "aggregates"
+	}
+	if len(c.Histogram) == 0 {
+		c.Histogram = "histogram"
 	}
+	if len(c.Exact) == 0 {
+		c.Exact = "exact"
+	}
+	if len(c.HasNext) == 0 {
+		c.HasNext = "hasNext"
+	}
+	isExtendedSearchModelType := IsExtendedFromSearchModel(searchModelType)
 
 	paramIndex := BuildParamIndex(searchModelType)
 	searchModelParamIndex := BuildParamIndex(reflect.TypeOf(SearchModel{}))
+	if !isExtendedSearchModelType {
+		// the search model is SearchModel, of which the params are in paramIndex
+		searchModelParamIndex = map[string]int{}
+	}
 	searchModelIndex := FindSearchModelIndex(searchModelType)
 
-	return &SearchHandler{search: search, searchModelType: searchModelType, Config: c, Log: writeLog, quickSearch: quickSearch, isExtendedSearchModelType: isExtendedSearchModelType, Resource: resource, Action: action, paramIndex: paramIndex, searchModelIndex: searchModelIndex, searchModelParamIndex: searchModelParamIndex, userId: userId, embedField: embedField, Error: logError}
+	var paging *PagingConfig
+	if len(options) >= 1 {
+		paging = &options[0]
+	}
+	return &SearchHandler{search: search, searchModelType: searchModelType, Config: c, Log: writeLog, quickSearch: quickSearch, isExtendedSearchModelType: isExtendedSearchModelType, Resource: resource, Action: action, paramIndex: paramIndex, searchModelIndex: searchModelIndex, searchModelParamIndex: searchModelParamIndex, userId: userId, embedField: embedField, Error: logError, Paging: paging}
 }
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSearchHooks(t *testing.T) {
+	builder, f := newUserDB(t, 2)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	calls := make([]string, 0)
+	h.BeforeSearch = append(h.BeforeSearch, func(ctx context.Context, m interface{}) error {
+		calls = append(calls, "before1")
+		m.(*testUserSM).Status = []string{"A"}
+		return nil
+	}, func(ctx context.Context, m interface{}) error {
+		calls = append(calls, "before2")
+		return nil
+	})
+	h.AfterSearch = append(h.AfterSearch, func(ctx context.Context, m interface{}, results interface{}, total int64) (interface{}, error) {
+		calls = append(calls, "after")
+		users := *results.(*[]testUser)
+		for i := range users {
+			users[i].Email = ""
+		}
+		return &users, nil
+	})
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?status=I", nil))
+	if want := []string{"before1", "before2", "after"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if q := f.Queries()[0]; !reflect.DeepEqual(q.Args, []interface{}{"A"}) {
+		t.Errorf("the before hook must force the filter: %q %v", q.Query, q.Args)
+	}
+	var result struct {
+		Results []testUser `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 2 || result.Results[0].Email != "" {
+		t.Errorf("the after hook must redact: %+v", result.Results)
+	}
+}
+
+func TestBeforeSearchStops(t *testing.T) {
+	builder, f := newUserDB(t, 2)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(testUserSM{}), nil, nil)
+	h.BeforeSearch = append(h.BeforeSearch, func(ctx context.Context, m interface{}) error {
+		return NewForbiddenError("not allowed")
+	})
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := httptest.NewRecorder()
+		h.Search(w, httptest.NewRequest(method, "/users", nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d", method, w.Code)
+		}
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("queries = %+v", f.Queries())
+	}
+}
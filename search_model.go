@@ -18,6 +18,19 @@ type SearchModel struct {
 	Keyword       string                   `mapstructure:"keyword" json:"keyword,omitempty" gorm:"column:keyword" bson:"keyword,omitempty" dynamodbav:"keyword,omitempty" firestore:"keyword,omitempty"`
 	Excluding     map[string][]interface{} `mapstructure:"excluding" json:"excluding,omitempty" gorm:"column:excluding" bson:"excluding,omitempty" dynamodbav:"excluding,omitempty" firestore:"excluding,omitempty"`
 	RefId         string                   `mapstructure:"refid" json:"refId,omitempty" gorm:"column:refid" bson:"refId,omitempty" dynamodbav:"refId,omitempty" firestore:"refId,omitempty"`
+	Facets        []string                 `mapstructure:"facets" json:"facets,omitempty" gorm:"column:facets" bson:"facets,omitempty" dynamodbav:"facets,omitempty" firestore:"facets,omitempty"`
+	Aggregates    []string                 `mapstructure:"aggregates" json:"aggregates,omitempty" gorm:"column:aggregates" bson:"aggregates,omitempty" dynamodbav:"aggregates,omitempty" firestore:"aggregates,omitempty"`
+	Histogram     *Histogram               `mapstructure:"histogram" json:"histogram,omitempty" gorm:"column:histogram" bson:"histogram,omitempty" dynamodbav:"histogram,omitempty" firestore:"histogram,omitempty"`
+}
+
+// searchModelGetter is implemented by *SearchModel and the structs, which embed SearchModel or *SearchModel
+type searchModelGetter interface {
+	GetSearchModel() *SearchModel
+}
+
+// GetSearchModel returns the search model; it is promoted to the structs, which embed SearchModel or *SearchModel
+func (s *SearchModel) GetSearchModel() *SearchModel {
+	return s
 }
 
 func IsExtendedFromSearchModel(searchModelType reflect.Type) bool {
@@ -31,21 +44,17 @@ func IsExtendedFromSearchModel(searchModelType reflect.Type) bool {
 			if _, ok := value.Field(i).Interface().(*SearchModel); ok {
 				return true
 			}
+			if f := searchModelType.Field(i); f.Anonymous && f.Type == reflect.TypeOf(SearchModel{}) {
+				return true
+			}
 		}
 	}
 	return false
 }
 func GetSearchModel(m interface{}) *SearchModel {
-	if sModel, ok := m.(*SearchModel); ok {
-		return sModel
+	if g, ok := m.(searchModelGetter); ok {
+		return g.GetSearchModel()
 	} else {
-		value := reflect.Indirect(reflect.ValueOf(m))
-		numField := value.NumField()
-		for i := 0; i < numField; i++ {
-			if sModel1, ok := value.Field(i).Interface().(*SearchModel); ok {
-				return sModel1
-			}
-		}
+		return searchModelOf(reflect.Indirect(reflect.ValueOf(m)))
 	}
-	return nil
 }
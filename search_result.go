@@ -7,4 +7,9 @@ type SearchResultConfig struct {
 	PageIndex     string `mapstructure:"page_index" json:"pageIndex,omitempty" gorm:"column:pageindex" bson:"pageIndex,omitempty" dynamodbav:"pageIndex,omitempty" firestore:"pageIndex,omitempty"`
 	PageSize      string `mapstructure:"page_size" json:"pageSize,omitempty" gorm:"column:pagesize" bson:"pageSize,omitempty" dynamodbav:"pageSize,omitempty" firestore:"pageSize,omitempty"`
 	FirstPageSize string `mapstructure:"first_page_size" json:"firstPageSize,omitempty" gorm:"column:firstpagesize" bson:"firstPageSize,omitempty" dynamodbav:"firstPageSize,omitempty" firestore:"firstPageSize,omitempty"`
+	Facets        string `mapstructure:"facets" json:"facets,omitempty" gorm:"column:facets" bson:"facets,omitempty" dynamodbav:"facets,omitempty" firestore:"facets,omitempty"`
+	Aggregates    string `mapstructure:"aggregates" json:"aggregates,omitempty" gorm:"column:aggregates" bson:"aggregates,omitempty" dynamodbav:"aggregates,omitempty" firestore:"aggregates,omitempty"`
+	Histogram     string `mapstructure:"histogram" json:"histogram,omitempty" gorm:"column:histogram" bson:"histogram,omitempty" dynamodbav:"histogram,omitempty" firestore:"histogram,omitempty"`
+	Exact         string `mapstructure:"exact" json:"exact,omitempty" gorm:"column:exact" bson:"exact,omitempty" dynamodbav:"exact,omitempty" firestore:"exact,omitempty"`
+	HasNext       string `mapstructure:"has_next" json:"hasNext,omitempty" gorm:"column:hasnext" bson:"hasNext,omitempty" dynamodbav:"hasNext,omitempty" firestore:"hasNext,omitempty"`
 }
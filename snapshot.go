@@ -0,0 +1,58 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// GetSnapshotOptions returns the read only transaction options of the driver: repeatable read, snapshot for mssql and read only for oracle
+func GetSnapshotOptions(driver string) *sql.TxOptions {
+	switch driver {
+	case DriverMssql:
+		return &sql.TxOptions{Isolation: sql.LevelSnapshot, ReadOnly: true}
+	case DriverOracle:
+		return &sql.TxOptions{ReadOnly: true}
+	default:
+		return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+}
+
+// RunInSnapshot runs f in a read only snapshot transaction, passed to f by the context.
+// If db cannot begin the transaction, or the isolation level is not supported, f runs without transaction.
+func RunInSnapshot(ctx context.Context, db Querier, f func(ctx context.Context) error) error {
+	beginner, ok := unwrapQuerier(db).(txBeginner)
+	if !ok {
+		return f(ctx)
+	}
+	tx, err := beginner.BeginTx(ctx, GetSnapshotOptions(GetDriver(db)))
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return f(ctx)
+	}
+	err = f(WithTx(ctx, tx))
+	// the transaction is read only, so it is rolled back, even if a statement, such as a timed out count, aborted it
+	tx.Rollback()
+	if err != nil && ctx.Err() == nil && IsIsolationUnsupported(err) {
+		return f(ctx)
+	}
+	return err
+}
+
+var isolationMessages = []string{"snapshot isolation", "isolation level", "read-only transactions are not supported", "read only transactions are not supported"}
+
+func IsIsolationUnsupported(err error) bool {
+	s := strings.ToLower(err.Error())
+	for _, m := range isolationMessages {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
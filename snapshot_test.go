@@ -0,0 +1,87 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestGetSnapshotOptions(t *testing.T) {
+	if o := GetSnapshotOptions(DriverPostgres); o.Isolation != sql.LevelRepeatableRead || !o.ReadOnly {
+		t.Errorf("postgres = %+v", o)
+	}
+	if o := GetSnapshotOptions(DriverMssql); o.Isolation != sql.LevelSnapshot || !o.ReadOnly {
+		t.Errorf("mssql = %+v", o)
+	}
+	if o := GetSnapshotOptions(DriverOracle); o.Isolation != sql.LevelDefault || !o.ReadOnly {
+		t.Errorf("oracle = %+v", o)
+	}
+}
+
+func TestSnapshotSearch(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	builder.Snapshot = true
+	_, total, err := builder.Search(context.Background(), &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}})
+	if err != nil || total != 25 {
+		t.Fatal(total, err)
+	}
+	queries := f.Queries()
+	if len(queries) != 2 || !queries[0].InTx || !queries[1].InTx {
+		t.Errorf("the page and the count must run in the transaction: %+v", queries)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.begins) != 1 || !f.begins[0].ReadOnly || f.begins[0].Isolation != driver.IsolationLevel(sql.LevelRepeatableRead) {
+		t.Errorf("begins = %+v", f.begins)
+	}
+	if f.rollbacks != 1 {
+		t.Errorf("the read only transaction must be rolled back: %d", f.rollbacks)
+	}
+}
+
+func TestSnapshotWithoutPaging(t *testing.T) {
+	builder, f := newUserDB(t, 25)
+	builder.Snapshot = true
+	if _, _, err := builder.Search(context.Background(), &testUserSM{SearchModel: &SearchModel{}}); err != nil {
+		t.Fatal(err)
+	}
+	if queries := f.Queries(); len(queries) != 1 || queries[0].InTx {
+		t.Errorf("the search without paging has no count, so it needs no transaction: %+v", queries)
+	}
+}
+
+func TestRunInSnapshotFallback(t *testing.T) {
+	db, f := newFakeDB(t, nil)
+	calls := make([]bool, 0)
+	err := RunInSnapshot(context.Background(), db, func(ctx context.Context) error {
+		calls = append(calls, GetTx(ctx) != nil)
+		if GetTx(ctx) != nil {
+			return errors.New("pq: transaction isolation level not supported")
+		}
+		return nil
+	})
+	if err != nil || len(calls) != 2 || !calls[0] || calls[1] {
+		t.Errorf("err %v, calls %v", err, calls)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	calls = calls[:0]
+	err = RunInSnapshot(context.Background(), tx, func(ctx context.Context) error {
+		calls = append(calls, GetTx(ctx) != nil)
+		return nil
+	})
+	if err != nil || len(calls) != 1 || calls[0] {
+		t.Errorf("the transaction cannot begin a snapshot: err %v, calls %v", err, calls)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.begins) != 2 {
+		t.Errorf("begins = %d, want 2", len(f.begins))
+	}
+}
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"reflect"
@@ -8,68 +9,59 @@ import (
 )
 
 func GetFieldByJson(modelType reflect.Type, jsonName string) (int, string, string) {
-	numField := modelType.NumField()
-	for i := 0; i < numField; i++ {
-		field := modelType.Field(i)
-		tag1, ok1 := field.Tag.Lookup("json")
-		if ok1 && strings.Split(tag1, ",")[0] == jsonName {
-			if tag2, ok2 := field.Tag.Lookup("gorm"); ok2 {
-				if has := strings.Contains(tag2, "column"); has {
-					str1 := strings.Split(tag2, ";")
-					num := len(str1)
-					for k := 0; k < num; k++ {
-						str2 := strings.Split(str1[k], ":")
-						for j := 0; j < len(str2); j++ {
-							if str2[j] == "column" {
-								return i, field.Name, str2[j+1]
-							}
-						}
-					}
-				}
-			}
-			return i, field.Name, ""
-		}
+	if f, ok := GetMetadata(modelType).FieldByJson(jsonName); ok {
+		return f.Index, f.Name, f.Column
 	}
 	return -1, jsonName, jsonName
 }
 func GetColumnName(modelType reflect.Type, fieldName string) (col string, colExist bool) {
+	f, ok := GetMetadata(modelType).FieldByName(fieldName)
+	if !ok {
+		return getPromotedColumnName(modelType, fieldName)
+	}
+	if !f.HasGorm {
+		return "", true
+	}
+	if f.HasColumn {
+		return f.Column, true
+	}
+	//return gorm.ToColumnName(fieldName), false
+	return fieldName, false
+}
+
+// getPromotedColumnName gets the column of the field of the embedded struct
+func getPromotedColumnName(modelType reflect.Type, fieldName string) (string, bool) {
 	field, ok := modelType.FieldByName(fieldName)
 	if !ok {
 		return fieldName, false
 	}
-	tag2, ok2 := field.Tag.Lookup("gorm")
-	if !ok2 {
+	tag, ok := field.Tag.Lookup("gorm")
+	if !ok {
 		return "", true
 	}
-
-	if has := strings.Contains(tag2, "column"); has {
-		str1 := strings.Split(tag2, ";")
-		num := len(str1)
-		for i := 0; i < num; i++ {
-			str2 := strings.Split(str1[i], ":")
-			for j := 0; j < len(str2); j++ {
-				if str2[j] == "column" {
-					return str2[j+1], true
-				}
-			}
-		}
+	if column, ok := FindTag(tag, "column"); ok {
+		return column, true
 	}
-	//return gorm.ToColumnName(fieldName), false
 	return fieldName, false
 }
 
-func Count(db *sql.DB, sql string, values ...interface{}) (int64, error) {
+func Count(db Querier, sql string, values ...interface{}) (int64, error) {
+	return CountWithContext(context.Background(), db, sql, values...)
+}
+
+func CountWithContext(ctx context.Context, db Querier, sql string, values ...interface{}) (int64, error) {
 	var total int64
-	row := db.QueryRow(sql, values...)
-	err2 := row.Scan(&total)
-	if err2 != nil {
-		return total, err2
-	}
-	return total, nil
+	row := GetQuerier(ctx, db).QueryRowContext(ctx, sql, values...)
+	err := row.Scan(&total)
+	return total, err
+}
+
+func Query(db Querier, results interface{}, fieldsIndex map[string]int, sql string, values ...interface{}) error {
+	return QueryWithContext(context.Background(), db, results, fieldsIndex, sql, values...)
 }
 
-func Query(db *sql.DB, results interface{}, fieldsIndex map[string]int, sql string, values ...interface{}) error {
-	rows, er1 := db.Query(sql, values...)
+func QueryWithContext(ctx context.Context, db Querier, results interface{}, fieldsIndex map[string]int, sql string, values ...interface{}) error {
+	rows, er1 := GetQuerier(ctx, db).QueryContext(ctx, sql, values...)
 	if er1 != nil {
 		return er1
 	}
@@ -114,15 +106,19 @@ func Query(db *sql.DB, results interface{}, fieldsIndex map[string]int, sql stri
 	return nil
 }
 
-func QueryAndCount(db *sql.DB, results interface{}, count *int64, driverName string, sql string, values ...interface{}) error {
-	rows, er1 := db.Query(sql, values...)
+func QueryAndCount(db Querier, results interface{}, count *int64, driverName string, sql string, values ...interface{}) error {
+	return QueryAndCountWithContext(context.Background(), db, results, count, driverName, sql, values...)
+}
+
+func QueryAndCountWithContext(ctx context.Context, db Querier, results interface{}, count *int64, driverName string, sql string, values ...interface{}) error {
+	rows, er1 := GetQuerier(ctx, db).QueryContext(ctx, sql, values...)
 	if er1 != nil {
 		return er1
 	}
 	defer rows.Close()
 	modelType := reflect.TypeOf(results).Elem().Elem()
 
-	fieldsIndex, er0 := GetColumnIndexes(modelType, driverName)
+	fieldsIndex, er0 := getColumnIndexes(modelType, driverName)
 	if er0 != nil {
 		return er0
 	}
@@ -169,23 +165,20 @@ func StructScan(s interface{}, indexColumns []int) (r []interface{}) {
 	return
 }
 
+// GetColumnIndexes returns the copy of the field indexes by column of the metadata cache
 func GetColumnIndexes(modelType reflect.Type, driver string) (map[string]int, error) {
-	mapp := make(map[string]int, 0)
 	if modelType.Kind() != reflect.Struct {
-		return mapp, errors.New("bad type")
+		return make(map[string]int, 0), errors.New("bad type")
 	}
-	for i := 0; i < modelType.NumField(); i++ {
-		field := modelType.Field(i)
-		ormTag := field.Tag.Get("gorm")
-		column, ok := FindTag(ormTag, "column")
-		if ok {
-			if driver == DriverOracle {
-				column = strings.ToUpper(column)
-			}
-			mapp[column] = i
-		}
+	return GetMetadata(modelType).ColumnIndexes(driver), nil
+}
+
+// getColumnIndexes returns the shared field indexes by column of the metadata cache, without copy, for the queries
+func getColumnIndexes(modelType reflect.Type, driver string) (map[string]int, error) {
+	if modelType.Kind() != reflect.Struct {
+		return make(map[string]int, 0), errors.New("bad type")
 	}
-	return mapp, nil
+	return GetMetadata(modelType).getColumnIndexes(driver), nil
 }
 
 func FindTag(tag string, key string) (string, bool) {
@@ -284,27 +277,10 @@ func GetColumnNameForSearch(modelType reflect.Type, sortField string) string {
 	return sortField // injection
 }
 
+// GetColumnsSelect returns the copy of the columns of the metadata, to be modified by the caller
 func GetColumnsSelect(modelType reflect.Type) []string {
-	numField := modelType.NumField()
-	columnNameKeys := make([]string, 0)
-	for i := 0; i < numField; i++ {
-		field := modelType.Field(i)
-		ormTag := field.Tag.Get("gorm")
-		if has := strings.Contains(ormTag, "column"); has {
-			str1 := strings.Split(ormTag, ";")
-			num := len(str1)
-			for i := 0; i < num; i++ {
-				str2 := strings.Split(str1[i], ":")
-				for j := 0; j < len(str2); j++ {
-					if str2[j] == "column" {
-						columnName := str2[j+1]
-						columnNameKeys = append(columnNameKeys, columnName)
-					}
-				}
-			}
-		}
-	}
-	return columnNameKeys
+	columns := GetMetadata(modelType).Columns
+	return append(make([]string, 0, len(columns)), columns...)
 }
 
 func GetSortType(sortType string) string {
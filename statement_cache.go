@@ -0,0 +1,160 @@
+package search
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+)
+
+// StatementCache keeps the LRU of the prepared statements of the db, keyed by sql; it is a Querier, to be SearchBuilder.Database.
+// The queries of the transaction of the context are not prepared.
+type StatementCache struct {
+	DB      *sql.DB
+	MaxSize int
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List
+	closed  bool
+	stats   StatementStats
+}
+type StatementStats struct {
+	Hits      int64   `mapstructure:"hits" json:"hits" gorm:"column:hits" bson:"hits" dynamodbav:"hits" firestore:"hits"`
+	Misses    int64   `mapstructure:"misses" json:"misses" gorm:"column:misses" bson:"misses" dynamodbav:"misses" firestore:"misses"`
+	Evictions int64   `mapstructure:"evictions" json:"evictions" gorm:"column:evictions" bson:"evictions" dynamodbav:"evictions" firestore:"evictions"`
+	Errors    int64   `mapstructure:"errors" json:"errors" gorm:"column:errors" bson:"errors" dynamodbav:"errors" firestore:"errors"`
+	Size      int     `mapstructure:"size" json:"size" gorm:"column:size" bson:"size" dynamodbav:"size" firestore:"size"`
+	HitRate   float64 `mapstructure:"hit_rate" json:"hitRate" gorm:"column:hitrate" bson:"hitRate" dynamodbav:"hitRate" firestore:"hitRate"`
+}
+type statement struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func NewStatementCache(db *sql.DB, maxSize int) *StatementCache {
+	return &StatementCache{DB: db, MaxSize: maxSize, items: make(map[string]*list.Element), lru: list.New()}
+}
+
+// CacheStatements wraps the database of the builder by the statement cache, and returns the cache to be closed on shutdown
+func (b *SearchBuilder) CacheStatements(maxSize int) *StatementCache {
+	db, ok := b.Database.(*sql.DB)
+	if !ok {
+		return nil
+	}
+	c := NewStatementCache(db, maxSize)
+	b.Database = c
+	return c
+}
+
+func (c *StatementCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	s := c.get(ctx, query)
+	if s == nil {
+		return c.DB.QueryContext(ctx, query, args...)
+	}
+	defer c.release(s)
+	return s.stmt.QueryContext(ctx, args...)
+}
+func (c *StatementCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	s := c.get(ctx, query)
+	if s == nil {
+		return c.DB.QueryRowContext(ctx, query, args...)
+	}
+	defer c.release(s)
+	return s.stmt.QueryRowContext(ctx, args...)
+}
+func (c *StatementCache) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.DB.BeginTx(ctx, opts)
+}
+func (c *StatementCache) Driver() driver.Driver {
+	return c.DB.Driver()
+}
+
+// get returns the statement of the query with one more reference, or nil if the query cannot be prepared
+func (c *StatementCache) get(ctx context.Context, query string) *statement {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	if e, ok := c.items[query]; ok {
+		c.lru.MoveToFront(e)
+		s := e.Value.(*statement)
+		s.refs++
+		c.stats.Hits++
+		c.mu.Unlock()
+		return s
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	stmt, err := c.DB.PrepareContext(ctx, query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.stats.Errors++
+		return nil
+	}
+	if c.closed {
+		stmt.Close()
+		return nil
+	}
+	if e, ok := c.items[query]; ok {
+		// prepared by another search at the same time
+		stmt.Close()
+		s := e.Value.(*statement)
+		s.refs++
+		return s
+	}
+	s := &statement{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.lru.PushFront(s)
+	for c.MaxSize > 0 && c.lru.Len() > c.MaxSize {
+		c.evict(c.lru.Back())
+		c.stats.Evictions++
+	}
+	return s
+}
+func (c *StatementCache) release(s *statement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.refs--
+	if s.evicted && s.refs == 0 {
+		s.stmt.Close()
+	}
+}
+
+// evict removes the statement, which is closed when it is not in use; the rows of the statement can still be read after close
+func (c *StatementCache) evict(e *list.Element) {
+	s := e.Value.(*statement)
+	c.lru.Remove(e)
+	delete(c.items, s.query)
+	s.evicted = true
+	if s.refs == 0 {
+		s.stmt.Close()
+	}
+}
+func (c *StatementCache) Stats() StatementStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	if stats.Hits+stats.Misses > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+	}
+	return stats
+}
+
+// Close closes all statements; the next queries are not prepared
+func (c *StatementCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		c.evict(e)
+		e = next
+	}
+	return nil
+}
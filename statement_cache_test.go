@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func queryAndClose(t *testing.T, c *StatementCache, query string) {
+	rows, err := c.QueryContext(context.Background(), query)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rows.Close()
+}
+
+func TestStatementCacheEviction(t *testing.T) {
+	db, f := newFakeDB(t, nil)
+	c := NewStatementCache(db, 2)
+	for _, q := range []string{"select 1", "select 2", "select 1", "select 3", "select 1", "select 1"} {
+		queryAndClose(t, c, q)
+	}
+	stats := c.Stats()
+	if stats.Hits != 3 || stats.Misses != 3 || stats.Evictions != 1 || stats.Size != 2 || stats.HitRate != 0.5 {
+		t.Errorf("stats = %+v", stats)
+	}
+	f.mu.Lock()
+	if f.prepares != 3 || f.closes != 1 {
+		t.Errorf("prepares %d, closes %d: the evicted statement must be closed", f.prepares, f.closes)
+	}
+	f.mu.Unlock()
+
+	c.Close()
+	f.mu.Lock()
+	if f.closes != 3 {
+		t.Errorf("closes = %d after Close", f.closes)
+	}
+	f.mu.Unlock()
+	queryAndClose(t, c, "select 1")
+	if c.Stats().Size != 0 {
+		t.Error("the closed cache must not prepare")
+	}
+}
+
+func TestStatementCacheConcurrent(t *testing.T) {
+	db, f := newFakeDB(t, nil)
+	c := NewStatementCache(db, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				queryAndClose(t, c, "select "+strconv.Itoa((i+j)%5))
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 400 || stats.Size > 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+	c.Close()
+	db.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.prepares != f.closes {
+		t.Errorf("prepares %d, closes %d: every statement must be closed", f.prepares, f.closes)
+	}
+}
+
+func TestCacheStatements(t *testing.T) {
+	builder, f := newUserDB(t, 2)
+	c := builder.CacheStatements(10)
+	if c == nil {
+		t.Fatal("the builder of *sql.DB must cache statements")
+	}
+	defer c.Close()
+	sm := &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}}
+	for i := 0; i < 3; i++ {
+		if _, total, err := builder.Search(context.Background(), sm); err != nil || total != 2 {
+			t.Fatal(total, err)
+		}
+	}
+	if stats := c.Stats(); stats.Misses != 2 || stats.Hits != 4 {
+		t.Errorf("stats = %+v", stats)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.prepares != 2 {
+		t.Errorf("prepares = %d, want the page and the count", f.prepares)
+	}
+}
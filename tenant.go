@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	TenantSchema = "schema"
+	TenantTable  = "table"
+)
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type TenantConfig struct {
+	// the tenant column, added to the query as a mandatory condition
+	Column string
+	// TenantSchema routes to the schema of the tenant, TenantTable routes to the table with tenant as suffix
+	Mode    string
+	Resolve func(ctx context.Context) (string, error)
+}
+
+func NewTenantConfig(column string, key string, options ...string) *TenantConfig {
+	var mode string
+	if len(options) >= 1 {
+		mode = options[0]
+	}
+	return &TenantConfig{Column: column, Mode: mode, Resolve: NewTenantResolver(key)}
+}
+
+// NewTenantResolver reads the tenant from the context by key, like the userId of BuildSearchModel
+func NewTenantResolver(key string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		u := ctx.Value(key)
+		if u != nil {
+			if tenant, ok := u.(string); ok && len(tenant) > 0 {
+				return tenant, nil
+			}
+		}
+		return "", NewForbiddenError("tenant is required")
+	}
+}
+
+func GetTenantTable(tableName string, tenant string, mode string) (string, error) {
+	switch mode {
+	case TenantSchema, TenantTable:
+		if !tenantPattern.MatchString(tenant) {
+			return "", NewForbiddenError("invalid tenant " + tenant)
+		}
+		if mode == TenantSchema {
+			return tenant + "." + tableName, nil
+		}
+		return tableName + "_" + tenant, nil
+	default:
+		return tableName, nil
+	}
+}
+
+// AddConditions adds the conditions with ? placeholders to the where clause, numbering placeholders after the existing parameters
+func AddConditions(sql string, params []interface{}, conditions []string, values []interface{}, driver string) (string, []interface{}) {
+	if len(conditions) == 0 {
+		return sql, params
+	}
+	s := strings.Join(conditions, " AND ")
+	if driver == DriverPostgres || driver == DriverOracle {
+		s = ReplaceParametersFrom(s, len(params), driver)
+	}
+	k := strings.LastIndex(sql, " order by ")
+	if k < 0 {
+		k = len(sql)
+	}
+	w := strings.Index(sql, " where ")
+	var sql2 string
+	if w >= 0 && w < k {
+		sql2 = sql[0:w] + " where (" + sql[w+7:k] + ") AND " + s + sql[k:]
+	} else {
+		sql2 = sql[0:k] + " where " + s + sql[k:]
+	}
+	params2 := make([]interface{}, 0, len(params)+len(values))
+	params2 = append(params2, params...)
+	params2 = append(params2, values...)
+	return sql2, params2
+}
+func ReplaceParametersFrom(sql string, start int, driver string) string {
+	i := start
+	for strings.Contains(sql, "?") {
+		i++
+		sql = strings.Replace(sql, "?", BuildParam(i, driver), 1)
+	}
+	return sql
+}
+
+func (c *TenantConfig) Apply(ctx context.Context, sql string, params []interface{}, driver string) (string, []interface{}, error) {
+	if len(c.Column) == 0 {
+		return sql, params, nil
+	}
+	if c.Resolve == nil {
+		return sql, params, errors.New("tenant resolver is required")
+	}
+	tenant, err := c.Resolve(ctx)
+	if err != nil {
+		return sql, params, err
+	}
+	sql2, params2 := AddConditions(sql, params, []string{c.Column + " = ?"}, []interface{}{tenant}, driver)
+	return sql2, params2, nil
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAddConditions(t *testing.T) {
+	sql, params := AddConditions("select id from users where status = $1 or status = $2 order by id", []interface{}{"A", "I"}, []string{"tenant_id = ?"}, []interface{}{"t1"}, DriverPostgres)
+	if want := "select id from users where (status = $1 or status = $2) AND tenant_id = $3 order by id"; sql != want {
+		t.Errorf("sql = %q, want %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []interface{}{"A", "I", "t1"}) {
+		t.Errorf("params = %v", params)
+	}
+	sql, _ = AddConditions("select id from users order by id", nil, []string{"tenant_id = ?"}, []interface{}{"t1"}, DriverOracle)
+	if want := "select id from users where tenant_id = :val1 order by id"; sql != want {
+		t.Errorf("sql = %q, want %q", sql, want)
+	}
+}
+
+func TestGetTenantTable(t *testing.T) {
+	if table, err := GetTenantTable("users", "acme", TenantSchema); err != nil || table != "acme.users" {
+		t.Errorf("schema = %q %v", table, err)
+	}
+	if table, err := GetTenantTable("users", "acme", TenantTable); err != nil || table != "users_acme" {
+		t.Errorf("table = %q %v", table, err)
+	}
+	if _, err := GetTenantTable("users", "acme; drop table users", TenantTable); err == nil {
+		t.Error("invalid tenant must fail")
+	}
+}
+
+func TestTenantSearch(t *testing.T) {
+	db, f := newFakeDB(t, nil)
+	modelType := reflect.TypeOf(testUser{})
+	builder := NewTenantSearchBuilder(db, "users", modelType, NewTenantConfig("tenant_id", "tenant"), nil)
+	sm := &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}, Status: []string{"A"}}
+	if _, _, err := builder.Search(context.Background(), sm); err == nil {
+		t.Fatal("the search without tenant must fail")
+	} else if p := ToProblem(err); p.Status != http.StatusForbidden {
+		t.Errorf("problem = %+v", p)
+	}
+	ctx := context.WithValue(context.Background(), "tenant", "acme")
+	if _, _, err := builder.Search(ctx, sm); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range f.Queries() {
+		if !reflect.DeepEqual(q.Args, []interface{}{"A", "acme"}) {
+			t.Errorf("query = %q %v", q.Query, q.Args)
+		}
+	}
+
+	schema := NewTenantSearchBuilder(db, "users", modelType, NewTenantConfig("", "tenant", TenantSchema), nil)
+	sql, _, err := schema.Build(ctx, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "select  id,username,email,status,salary from acme.users where status in (?)"; sql != want {
+		t.Errorf("sql = %q, want %q", sql, want)
+	}
+}
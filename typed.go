@@ -0,0 +1,168 @@
+//go:build go1.18
+
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// Filter is the pointer to the search model F, which embeds SearchModel or *SearchModel, or is SearchModel.
+// It is the type parameter of the constructors, to check the search model at compile time.
+type Filter[F any] interface {
+	*F
+	GetSearchModel() *SearchModel
+}
+
+// TypedSearcher searches by the search model F and returns []T, without type assertion
+type TypedSearcher[T any, F any] struct {
+	search func(ctx context.Context, filter *F) ([]T, int64, error)
+	// run on every result, such as to convert the db values
+	Map func(ctx context.Context, model *T) error
+}
+
+func NewTypedSearcher[T any, F any, PF Filter[F]](search func(context.Context, *F) ([]T, int64, error), options ...func(context.Context, *T) error) *TypedSearcher[T, F] {
+	var mp func(context.Context, *T) error
+	if len(options) >= 1 {
+		mp = options[0]
+	}
+	return &TypedSearcher[T, F]{search: search, Map: mp}
+}
+
+// NewDefaultTypedSearcher searches the table by the query of F and T, like NewDefaultSearchBuilder, with the hidden fields of the context
+func NewDefaultTypedSearcher[T any, F any, PF Filter[F]](db *sql.DB, tableName string, options ...func(context.Context, *T) error) *TypedSearcher[T, F] {
+	modelType := reflect.TypeOf((*T)(nil)).Elem()
+	builder := NewDefaultSearchBuilder(db, tableName, modelType, nil)
+	return NewTypedSearcher[T, F, PF](TypedSearch[T, F](builder.Search), options...)
+}
+
+// TypedSearch adapts the search func of interface{}, such as SearchBuilder.Search, which returns *[]T or []T
+func TypedSearch[T any, F any](search func(context.Context, interface{}) (interface{}, int64, error)) func(context.Context, *F) ([]T, int64, error) {
+	return func(ctx context.Context, filter *F) ([]T, int64, error) {
+		results, total, err := search(ctx, filter)
+		if err != nil {
+			return nil, total, err
+		}
+		switch v := results.(type) {
+		case nil:
+			return nil, total, nil
+		case *[]T:
+			if v == nil {
+				return nil, total, nil
+			}
+			return *v, total, nil
+		case []T:
+			return v, total, nil
+		}
+		return nil, total, fmt.Errorf("expected *[]%s, got %T", reflect.TypeOf((*T)(nil)).Elem(), results)
+	}
+}
+
+func (s *TypedSearcher[T, F]) Search(ctx context.Context, filter *F) ([]T, int64, error) {
+	results, total, err := s.search(ctx, filter)
+	if err != nil || s.Map == nil {
+		return results, total, err
+	}
+	ctx2, end := StartSpan(ctx, StageMap)
+	for i := range results {
+		if err := s.Map(ctx2, &results[i]); err != nil {
+			end(int64(i), err)
+			return nil, total, err
+		}
+	}
+	end(int64(len(results)), nil)
+	return results, total, nil
+}
+
+// SearchAny keeps the interface{} API, such as NewSearcher(s.SearchAny); it returns *[]T like SearchBuilder.Search
+func (s *TypedSearcher[T, F]) SearchAny(ctx context.Context, searchModel interface{}) (interface{}, int64, error) {
+	filter, ok := searchModel.(*F)
+	if !ok {
+		return nil, 0, fmt.Errorf("expected *%s, got %T", reflect.TypeOf((*F)(nil)).Elem(), searchModel)
+	}
+	results, total, err := s.Search(ctx, filter)
+	if err != nil {
+		return nil, total, err
+	}
+	return &results, total, nil
+}
+func (s *TypedSearcher[T, F]) Service() SearchService {
+	return SearchFunc(s.SearchAny)
+}
+
+// TypedSearchHandler is the SearchHandler of the TypedSearcher, with the hooks of F and T
+type TypedSearchHandler[T any, F any] struct {
+	*SearchHandler
+	Searcher *TypedSearcher[T, F]
+}
+
+// NewTypedSearchHandler is NewSearchHandler of F, of which options are userId, resource and action
+func NewTypedSearchHandler[T any, F any, PF Filter[F]](searcher *TypedSearcher[T, F], logError func(context.Context, string), writeLog func(context.Context, string, string, bool, string) error, options ...string) *TypedSearchHandler[T, F] {
+	return NewTypedSearchHandlerWithQuickSearch[T, F, PF](searcher, logError, writeLog, true, options...)
+}
+
+// NewJSONTypedSearchHandler is NewJSONSearchHandler of F, which returns the results as json objects instead of csv
+func NewJSONTypedSearchHandler[T any, F any, PF Filter[F]](searcher *TypedSearcher[T, F], logError func(context.Context, string), writeLog func(context.Context, string, string, bool, string) error, options ...string) *TypedSearchHandler[T, F] {
+	return NewTypedSearchHandlerWithQuickSearch[T, F, PF](searcher, logError, writeLog, false, options...)
+}
+func NewTypedSearchHandlerWithQuickSearch[T any, F any, PF Filter[F]](searcher *TypedSearcher[T, F], logError func(context.Context, string), writeLog func(context.Context, string, string, bool, string) error, quickSearch bool, options ...string) *TypedSearchHandler[T, F] {
+	searchModelType := reflect.TypeOf((*F)(nil)).Elem()
+	user := UserId
+	resource := BuildResourceNameFromType(searchModelType)
+	action := Search
+	if len(options) >= 1 && len(options[0]) > 0 {
+		user = options[0]
+	}
+	if len(options) >= 2 && len(options[1]) > 0 {
+		resource = options[1]
+	}
+	if len(options) >= 3 && len(options[2]) > 0 {
+		action = options[2]
+	}
+	h := newSearchHandler(searcher.SearchAny, searchModelType, logError, nil, writeLog, quickSearch, resource, action, user, "")
+	return &TypedSearchHandler[T, F]{SearchHandler: h, Searcher: searcher}
+}
+
+// Before adds the hooks, which run in order before search, such as to force tenant or owner filters
+func (h *TypedSearchHandler[T, F]) Before(hooks ...func(ctx context.Context, filter *F) error) {
+	for _, hook := range hooks {
+		hook := hook
+		h.BeforeSearch = append(h.BeforeSearch, func(ctx context.Context, searchModel interface{}) error {
+			return hook(ctx, searchModel.(*F))
+		})
+	}
+}
+
+// After adds the hooks, which run in order after search, such as to redact results.
+// The results of the previous hooks may be *[]T or []T, and are returned in the same form.
+func (h *TypedSearchHandler[T, F]) After(hooks ...func(ctx context.Context, filter *F, results []T, total int64) ([]T, error)) {
+	for _, hook := range hooks {
+		hook := hook
+		h.AfterSearch = append(h.AfterSearch, func(ctx context.Context, searchModel interface{}, results interface{}, total int64) (interface{}, error) {
+			var models []T
+			isSlice := false
+			switch v := results.(type) {
+			case nil:
+			case *[]T:
+				if v != nil {
+					models = *v
+				}
+			case []T:
+				models = v
+				isSlice = true
+			default:
+				return nil, fmt.Errorf("expected *[]%s, got %T", reflect.TypeOf((*T)(nil)).Elem(), results)
+			}
+			models, err := hook(ctx, searchModel.(*F), models, total)
+			if err != nil {
+				return nil, err
+			}
+			if isSlice {
+				return models, nil
+			}
+			return &models, nil
+		})
+	}
+}
@@ -0,0 +1,108 @@
+//go:build go1.18
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTypedSearch(t *testing.T) {
+	users := []testUser{{Id: "1"}, {Id: "2"}}
+	for _, results := range []interface{}{&users, users} {
+		results := results
+		search := TypedSearch[testUser, testUserSM](func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+			return results, 2, nil
+		})
+		if r, total, err := search(context.Background(), &testUserSM{}); err != nil || total != 2 || len(r) != 2 {
+			t.Errorf("%T: %v %d %v", results, r, total, err)
+		}
+	}
+	search := TypedSearch[testUser, testUserSM](func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		return &[]string{"x"}, 1, nil
+	})
+	if _, _, err := search(context.Background(), &testUserSM{}); err == nil {
+		t.Error("the results of another type must fail")
+	}
+}
+
+func TestDefaultTypedSearcher(t *testing.T) {
+	db, f := newFakeDB(t, func(ctx context.Context, query string, args []interface{}) (*fakeRows, error) {
+		if isCountQuery(query) {
+			return totalOf(2), nil
+		}
+		return rowsOf(testUserColumns, testUserRow("1", "john"), testUserRow("2", "joe")), nil
+	})
+	searcher := NewDefaultTypedSearcher[testUser, testUserSM, *testUserSM](db, "users", func(ctx context.Context, u *testUser) error {
+		u.Email = strings.ToUpper(u.Email)
+		return nil
+	})
+	users, total, err := searcher.Search(context.Background(), &testUserSM{SearchModel: &SearchModel{Limit: 10, Page: 1}, Status: []string{"A"}})
+	if err != nil || total != 2 || len(users) != 2 || users[0].Email != "JOHN@TEST.COM" {
+		t.Fatalf("%+v %d %v", users, total, err)
+	}
+	if q := f.Queries()[0]; q.Query != "select  id,username,email,status,salary from users where status in (?) limit 10 offset 0 " {
+		t.Errorf("query = %+v", q)
+	}
+	if _, _, err := searcher.SearchAny(context.Background(), &orderSM{}); err == nil {
+		t.Error("SearchAny must reject another search model")
+	}
+	ctx := WithHiddenFields(context.Background(), &HiddenFields{Names: map[string]bool{"email": true}})
+	if _, _, err := searcher.Search(ctx, &testUserSM{SearchModel: &SearchModel{Keyword: "jo"}}); err != nil {
+		t.Fatal(err)
+	}
+	queries := f.Queries()
+	if q := queries[len(queries)-1]; q.Query != "select  id,username,email,status,salary from users where (username like ?)" {
+		t.Errorf("the keyword must not search the hidden fields of the context: %+v", q)
+	}
+}
+
+func TestTypedSearchHandlerAfter(t *testing.T) {
+	builder, _ := newUserDB(t, 2)
+	searcher := NewTypedSearcher[testUser, testUserSM, *testUserSM](TypedSearch[testUser, testUserSM](builder.Search))
+	h := NewJSONTypedSearchHandler[testUser, testUserSM, *testUserSM](searcher, nil, nil)
+	// the hook of SearchHandler returns []T instead of *[]T
+	h.AfterSearch = append(h.AfterSearch, func(ctx context.Context, m interface{}, results interface{}, total int64) (interface{}, error) {
+		return *results.(*[]testUser), nil
+	})
+	var seen int
+	h.After(func(ctx context.Context, filter *testUserSM, results []testUser, total int64) ([]testUser, error) {
+		seen = len(results)
+		for i := range results {
+			results[i].Email = ""
+		}
+		return results, nil
+	})
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/users?fields=id,email", nil))
+	var result struct {
+		Results []testUser `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if seen != 2 || len(result.Results) != 2 || result.Results[0].Id != "1" || result.Results[0].Email != "" {
+		t.Errorf("seen %d, results %+v", seen, result.Results)
+	}
+}
+
+func TestTypedSearchHandlerQuickSearch(t *testing.T) {
+	builder, _ := newUserDB(t, 2)
+	searcher := NewTypedSearcher[testUser, testUserSM, *testUserSM](TypedSearch[testUser, testUserSM](builder.Search))
+	for _, quickSearch := range []bool{true, false} {
+		h := NewTypedSearchHandlerWithQuickSearch[testUser, testUserSM, *testUserSM](searcher, nil, nil, quickSearch)
+		w := httptest.NewRecorder()
+		h.Search(w, httptest.NewRequest(http.MethodGet, "/users?fields=id,username", nil))
+		var result interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		if _, csv := result.(string); csv != quickSearch {
+			t.Errorf("quickSearch %v: body = %s", quickSearch, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// DecodeUrl sets the query parameters to the search model.
+// Repeated parameters fill slices, and dotted parameters such as "createdDate.startDate" or "price.min" fill nested structs.
+func DecodeUrl(searchModel interface{}, params url.Values, searchModelParamIndex map[string]int, searchModelIndex int, paramIndex map[string]int) error {
+	value := reflect.Indirect(reflect.ValueOf(searchModel))
+	if value.Kind() == reflect.Ptr {
+		value = reflect.Indirect(value)
+	}
+	for paramKey, valueArr := range params {
+		if len(valueArr) == 0 {
+			continue
+		}
+		keys := strings.Split(paramKey, ".")
+		err, field := FindField(value, keys[0], searchModelParamIndex, searchModelIndex, paramIndex)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, key := range keys[1:] {
+			field, err = findSubField(field, key)
+			if err != nil {
+				return fmt.Errorf("invalid parameter %s: %s", paramKey, err.Error())
+			}
+		}
+		if err = SetValue(field, valueArr); err != nil {
+			return fmt.Errorf("invalid parameter %s: %s", paramKey, err.Error())
+		}
+	}
+	return nil
+}
+func findSubField(field reflect.Value, jsonName string) (reflect.Value, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Struct {
+		return field, errors.New("cannot find field " + jsonName)
+	}
+	i, _ := findIndexByTagJson(field.Type(), jsonName)
+	if i < 0 {
+		return field, errors.New("cannot find field " + jsonName)
+	}
+	return field.Field(i), nil
+}
+
+// SetValue parses the text values to the field
+func SetValue(field reflect.Value, values []string) error {
+	t := field.Type()
+	switch field.Kind() {
+	case reflect.Ptr:
+		v := reflect.New(t.Elem())
+		if err := SetValue(v.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(v)
+		return nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(values[0]))
+			return nil
+		}
+		items := make([]string, 0)
+		for _, v := range values {
+			for _, s := range strings.Split(v, ",") {
+				items = append(items, s)
+			}
+		}
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, s := range items {
+			if err := SetValue(slice.Index(i), []string{s}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			d, err := ParseTime(values[0])
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(d))
+			return nil
+		}
+		return decodeJson(field, values[0])
+	case reflect.Map:
+		return decodeJson(field, values[0])
+	default:
+		return SetScalar(field, values[0])
+	}
+}
+func SetScalar(field reflect.Value, s string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		if len(s) == 0 {
+			field.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a bool", s)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid %s", s, field.Type().String())
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid %s", s, field.Type().String())
+		}
+		field.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid %s", s, field.Type().String())
+		}
+		field.SetFloat(f)
+	case reflect.Interface:
+		field.Set(reflect.ValueOf(s))
+	default:
+		return errors.New("unsupported type " + field.Type().String())
+	}
+	return nil
+}
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid time", s)
+}
+func decodeJson(field reflect.Value, s string) error {
+	v := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(s), v.Interface()); err != nil {
+		return err
+	}
+	field.Set(v.Elem())
+	return nil
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type orderSM struct {
+	*SearchModel
+	Id       []int64      `json:"id"`
+	Active   *bool        `json:"active"`
+	Quantity int32        `json:"quantity"`
+	Created  *DateRange   `json:"created"`
+	Amount   *NumberRange `json:"amount"`
+	Paid     time.Time    `json:"paid"`
+}
+
+func decodeOrder(t *testing.T, query string) (*orderSM, error) {
+	searchModelType := reflect.TypeOf(orderSM{})
+	r := httptest.NewRequest(http.MethodGet, "/orders?"+query, nil)
+	m, _, err := DecodeSearchModel(r, searchModelType, true, BuildParamIndex(reflect.TypeOf(SearchModel{})), FindSearchModelIndex(searchModelType), BuildParamIndex(searchModelType))
+	if err != nil {
+		return nil, err
+	}
+	return m.(*orderSM), nil
+}
+
+func TestDecodeUrl(t *testing.T) {
+	sm, err := decodeOrder(t, "id=1,2&id=3&active=false&quantity=5&created.startDate=2024-01-02&created.endDate=2024-01-31T10:00:00Z&amount.min=10.5&paid=2024-02-01+08:00:00&page=2&limit=20&sort=-id&fields=id,quantity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sm.Id, []int64{1, 2, 3}) {
+		t.Errorf("id = %v", sm.Id)
+	}
+	if sm.Active == nil || *sm.Active {
+		t.Errorf("active = %v, want pointer to false", sm.Active)
+	}
+	if sm.Quantity != 5 {
+		t.Errorf("quantity = %d", sm.Quantity)
+	}
+	if sm.Created == nil || sm.Created.StartDate == nil || sm.Created.StartDate.Format(bucketLayout) != "2024-01-02" || sm.Created.EndDate.Hour() != 10 {
+		t.Errorf("created = %+v", sm.Created)
+	}
+	if sm.Amount == nil || sm.Amount.Min == nil || *sm.Amount.Min != 10.5 || sm.Amount.Max != nil {
+		t.Errorf("amount = %+v", sm.Amount)
+	}
+	if sm.Paid.Hour() != 8 {
+		t.Errorf("paid = %v", sm.Paid)
+	}
+	if sm.Page != 2 || sm.Limit != 20 || sm.Sort != "-id" || !reflect.DeepEqual(sm.Fields, []string{"id", "quantity"}) {
+		t.Errorf("search model = %+v", sm.SearchModel)
+	}
+}
+
+func TestDecodeUrlErrors(t *testing.T) {
+	for _, query := range []string{"quantity=abc", "active=maybe", "created.unknown=1", "paid=yesterday", "quantity=99999999999"} {
+		if _, err := decodeOrder(t, query); err == nil {
+			t.Errorf("%s must fail", query)
+		}
+	}
+	if _, err := decodeOrder(t, "unknown=1"); err != nil {
+		t.Errorf("unknown parameters are ignored: %v", err)
+	}
+}
+
+func TestDecodeErrorIsBadRequest(t *testing.T) {
+	builder, f := newUserDB(t, 0)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(orderSM{}), nil, nil)
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/orders?quantity=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, body %s", w.Code, w.Body.String())
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("queries = %+v", f.Queries())
+	}
+}
@@ -0,0 +1,201 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RuleRequired  = "required"
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RuleMaxLength = "maxLength"
+	RuleIn        = "in"
+	RuleMaxItems  = "maxItems"
+)
+
+type ErrorMessage struct {
+	Field   string `mapstructure:"field" json:"field,omitempty" gorm:"column:field" bson:"field,omitempty" dynamodbav:"field,omitempty" firestore:"field,omitempty"`
+	Code    string `mapstructure:"code" json:"code,omitempty" gorm:"column:code" bson:"code,omitempty" dynamodbav:"code,omitempty" firestore:"code,omitempty"`
+	Param   string `mapstructure:"param" json:"param,omitempty" gorm:"column:param" bson:"param,omitempty" dynamodbav:"param,omitempty" firestore:"param,omitempty"`
+	Message string `mapstructure:"message" json:"message,omitempty" gorm:"column:message" bson:"message,omitempty" dynamodbav:"message,omitempty" firestore:"message,omitempty"`
+}
+
+// Validate checks the search model by the validate tag, such as `validate:"required,maxLength=100,in=A|I,min=0,max=10,maxItems=50"`.
+// All violations are returned, with the field path by json names. The limit must not be greater than MaxPageSizeDefault.
+// As the client may not send a field, in skips the zero value, and min/max skip the zero value of a field, which is neither a pointer nor required.
+func Validate(searchModel interface{}) []ErrorMessage {
+	return validate(searchModel, MaxPageSizeDefault)
+}
+
+// NewValidator returns Validate with the max page size, such as the MaxPageSize of PagingConfig above MaxPageSizeDefault
+func NewValidator(maxPageSize int64) func(searchModel interface{}) []ErrorMessage {
+	return func(searchModel interface{}) []ErrorMessage {
+		return validate(searchModel, maxPageSize)
+	}
+}
+func validate(searchModel interface{}, maxPageSize int64) []ErrorMessage {
+	errs := make([]ErrorMessage, 0)
+	if sm := GetSearchModel(searchModel); sm != nil {
+		errs = ValidatePaging(sm, maxPageSize, errs)
+	}
+	value := reflect.Indirect(reflect.ValueOf(searchModel))
+	if value.Kind() != reflect.Struct {
+		return errs
+	}
+	return validateStruct(value, "", errs)
+}
+func ValidatePaging(sm *SearchModel, maxPageSize int64, errs []ErrorMessage) []ErrorMessage {
+	if sm.Limit < 0 {
+		errs = append(errs, ErrorMessage{Field: "limit", Code: RuleMin, Param: "0", Message: "limit must be greater than or equal to 0"})
+	} else if maxPageSize > 0 && sm.Limit > maxPageSize {
+		p := strconv.FormatInt(maxPageSize, 10)
+		errs = append(errs, ErrorMessage{Field: "limit", Code: RuleMax, Param: p, Message: "limit must be less than or equal to " + p})
+	}
+	if sm.FirstLimit < 0 {
+		errs = append(errs, ErrorMessage{Field: "firstLimit", Code: RuleMin, Param: "0", Message: "firstLimit must be greater than or equal to 0"})
+	}
+	if sm.Page < 0 {
+		errs = append(errs, ErrorMessage{Field: "page", Code: RuleMin, Param: "0", Message: "page must be greater than or equal to 0"})
+	}
+	return errs
+}
+func validateStruct(value reflect.Value, prefix string, errs []ErrorMessage) []ErrorMessage {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if len(sf.PkgPath) > 0 {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		path := prefix
+		if len(name) > 0 {
+			path = joinPath(prefix, name)
+		} else if !sf.Anonymous {
+			path = joinPath(prefix, sf.Name)
+		}
+		field := value.Field(i)
+		if tag, ok := sf.Tag.Lookup("validate"); ok {
+			errs = ValidateField(field, path, tag, errs)
+		}
+		f := reflect.Indirect(field)
+		if f.Kind() == reflect.Struct && f.Type() != reflect.TypeOf(time.Time{}) && f.Type() != reflect.TypeOf(SearchModel{}) {
+			errs = validateStruct(f, path, errs)
+		}
+	}
+	return errs
+}
+func joinPath(prefix string, name string) string {
+	if len(prefix) == 0 {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// ValidateField checks one field by the rules of the validate tag
+func ValidateField(field reflect.Value, path string, tag string, errs []ErrorMessage) []ErrorMessage {
+	rules := strings.Split(tag, ",")
+	// the zero value of an optional field, which is not a pointer, may be the one the client did not send
+	optional := !hasRule(rules, RuleRequired)
+	if field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			if !optional {
+				errs = append(errs, ErrorMessage{Field: path, Code: RuleRequired, Message: path + " is required"})
+			}
+			return errs
+		}
+		field = field.Elem()
+		optional = false
+	}
+	for _, rule := range rules {
+		code, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			code, param = strings.TrimSpace(rule[0:i]), strings.TrimSpace(rule[i+1:])
+		}
+		switch code {
+		case RuleRequired:
+			if field.IsZero() || ((field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() == 0) {
+				errs = append(errs, ErrorMessage{Field: path, Code: code, Message: path + " is required"})
+			}
+		case RuleMaxItems:
+			n, _ := strconv.Atoi(param)
+			if (field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.Len() > n {
+				errs = append(errs, ErrorMessage{Field: path, Code: code, Param: param, Message: fmt.Sprintf("%s must not have more than %s items", path, param)})
+			}
+		default:
+			if field.Kind() == reflect.Slice {
+				for j := 0; j < field.Len(); j++ {
+					errs = validateValue(reflect.Indirect(field.Index(j)), path+"["+strconv.Itoa(j)+"]", code, param, false, errs)
+				}
+			} else {
+				errs = validateValue(field, path, code, param, optional, errs)
+			}
+		}
+	}
+	return errs
+}
+func validateValue(field reflect.Value, path string, code string, param string, optional bool, errs []ErrorMessage) []ErrorMessage {
+	switch code {
+	case RuleMaxLength:
+		n, _ := strconv.Atoi(param)
+		if field.Kind() == reflect.String && len([]rune(field.String())) > n {
+			errs = append(errs, ErrorMessage{Field: path, Code: code, Param: param, Message: fmt.Sprintf("%s must not be longer than %s characters", path, param)})
+		}
+	case RuleIn:
+		if field.IsZero() {
+			return errs
+		}
+		s := fmt.Sprintf("%v", field.Interface())
+		allowed := strings.Split(param, "|")
+		for _, a := range allowed {
+			if a == s {
+				return errs
+			}
+		}
+		errs = append(errs, ErrorMessage{Field: path, Code: code, Param: param, Message: path + " must be one of " + strings.Join(allowed, ", ")})
+	case RuleMin, RuleMax:
+		if optional && field.IsZero() {
+			return errs
+		}
+		v, ok := toFloat(field)
+		if !ok {
+			return errs
+		}
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return errs
+		}
+		if code == RuleMin && v < limit {
+			errs = append(errs, ErrorMessage{Field: path, Code: code, Param: param, Message: path + " must be greater than or equal to " + param})
+		} else if code == RuleMax && v > limit {
+			errs = append(errs, ErrorMessage{Field: path, Code: code, Param: param, Message: path + " must be less than or equal to " + param})
+		}
+	}
+	return errs
+}
+func hasRule(rules []string, code string) bool {
+	for _, rule := range rules {
+		if strings.TrimSpace(rule) == code {
+			return true
+		}
+	}
+	return false
+}
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type productSM struct {
+	*SearchModel
+	Name     string       `json:"name" validate:"maxLength=5"`
+	Status   []string     `json:"status" validate:"in=A|I,maxItems=2"`
+	Category *string      `json:"category" validate:"required"`
+	Price    *NumberRange `json:"price"`
+	Rating   int          `json:"rating" validate:"min=1,max=5"`
+}
+
+func errorFields(errs []ErrorMessage) []string {
+	fields := make([]string, 0)
+	for _, e := range errs {
+		fields = append(fields, e.Field+":"+e.Code)
+	}
+	return fields
+}
+
+func TestValidate(t *testing.T) {
+	category := "book"
+	sm := &productSM{SearchModel: &SearchModel{Limit: 20}, Name: "abc", Status: []string{"A"}, Category: &category, Rating: 3}
+	if errs := Validate(sm); len(errs) != 0 {
+		t.Errorf("valid search model: %v", errs)
+	}
+	sm = &productSM{SearchModel: &SearchModel{Limit: -1, Page: -1}, Name: "abcdef", Status: []string{"A", "X", "I"}, Rating: 6}
+	want := []string{"limit:min", "page:min", "name:maxLength", "status[1]:in", "status:maxItems", "category:required", "rating:max"}
+	if fields := errorFields(Validate(sm)); !reflect.DeepEqual(fields, want) {
+		t.Errorf("errors = %v, want %v", fields, want)
+	}
+}
+
+func TestValidateRespondsAllViolations(t *testing.T) {
+	builder, f := newUserDB(t, 0)
+	h := NewSearchHandler(builder.Search, reflect.TypeOf(productSM{}), nil, nil)
+	h.Validate = Validate
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/products?name=abcdef&rating=9", nil))
+	if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Header().Get("Content-Type"), ProblemContentType) {
+		t.Fatalf("status %d, content type %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name:maxLength", "category:required", "rating:max"}; !reflect.DeepEqual(errorFields(p.Errors), want) {
+		t.Errorf("errors = %v, want %v", errorFields(p.Errors), want)
+	}
+	if len(f.Queries()) != 0 {
+		t.Errorf("invalid search model must not query: %+v", f.Queries())
+	}
+}
+
+func TestValidateOptionalMinMax(t *testing.T) {
+	type reviewSM struct {
+		*SearchModel
+		Rating    int     `json:"rating" validate:"min=1,max=5"`
+		MinRating *int    `json:"minRating" validate:"min=1"`
+		Stars     int     `json:"stars" validate:"required,min=1"`
+		Scores    []int64 `json:"scores" validate:"min=1"`
+	}
+	sm := &reviewSM{SearchModel: &SearchModel{}, Stars: 2}
+	if errs := Validate(sm); len(errs) != 0 {
+		t.Errorf("the fields, which are not provided, must not be validated: %v", errs)
+	}
+	zero := 0
+	sm = &reviewSM{SearchModel: &SearchModel{}, MinRating: &zero, Scores: []int64{0}}
+	want := []string{"minRating:min", "stars:required", "stars:min", "scores[0]:min"}
+	if fields := errorFields(Validate(sm)); !reflect.DeepEqual(fields, want) {
+		t.Errorf("errors = %v, want %v", fields, want)
+	}
+	search := func(ctx context.Context, m interface{}) (interface{}, int64, error) {
+		return &[]testUser{}, 0, nil
+	}
+	h := NewSearchHandler(search, reflect.TypeOf(productSM{}), nil, nil)
+	h.Validate = Validate
+	w := httptest.NewRecorder()
+	h.Search(w, httptest.NewRequest(http.MethodGet, "/products?category=book", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("the search without rating must be valid, status %d: %s", w.Code, w.Body.String())
+	}
+}